@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//Alerts is the outside object from Twilios Monitor Alerts api
+type Alerts struct {
+	Alerts []AlertRecords `json:"alerts"`
+}
+
+//AlertRecords are the inside objects from Twilios Monitor Alerts api
+type AlertRecords struct {
+	Sid         string `json:"sid"`
+	AccountSid  string `json:"account_sid"`
+	DateCreated string `json:"date_created"`
+	ErrorCode   string `json:"error_code"`
+	LogLevel    string `json:"log_level"`
+	ResourceSid string `json:"resource_sid"`
+}
+
+//resourceTypes maps the two letter prefix of a Twilio sid to the kind of resource it identifies
+var resourceTypes = map[string]string{
+	"AC": "account",
+	"AP": "application",
+	"CA": "call",
+	"CF": "conference",
+	"FX": "fax",
+	"MG": "messaging_service",
+	"MM": "message",
+	"PN": "phone_number",
+	"QU": "queue",
+	"RE": "recording",
+	"SM": "message",
+	"TR": "transcription",
+	"TK": "trunk",
+	"WS": "taskrouter_workspace",
+	"ZS": "function_service",
+}
+
+//resourceType returns the kind of resource sid identifies, its prefix when the kind is unknown and empty without a sid
+func resourceType(sid string) string {
+	if len(sid) < 2 {
+		return ""
+	}
+	if kind, ok := resourceTypes[sid[:2]]; ok {
+		return kind
+	}
+	return sid[:2]
+}
+
+//AlertsCollector counts the alerts of Twilio's debugger by error code as they are raised, reading the Monitor Alerts api incrementally from a high-water mark on DateCreated.
+//Alerts are written after the fact, so they are only counted once they are older than settle.
+type AlertsCollector struct {
+	targets []accountTarget
+	settle  time.Duration
+	alerts  *prometheus.CounterVec
+
+	mu    sync.Mutex
+	marks map[string]*highWaterMark
+}
+
+//newAlertsCollector creates a collector counting alerts of targets raised from now on
+func newAlertsCollector(targets []accountTarget, settle time.Duration) *AlertsCollector {
+	return &AlertsCollector{
+		targets: targets,
+		settle:  settle,
+		alerts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "twil_alerts_total",
+			Help: "Debugger alerts raised since twil started, by error code, log level and the type of resource they concern",
+		}, []string{"account_sid", "error_code", "log_level", "resource_type", "description"}),
+		marks: map[string]*highWaterMark{},
+	}
+}
+
+//alertsErrorDesc identifies the invalid metrics of failed alert polls
+var alertsErrorDesc = prometheus.NewDesc("twil_alerts_total", "Debugger alerts raised since twil started", nil, nil)
+
+//Describe initializes channels used to pull Metrics
+func (c *AlertsCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.alerts.Describe(ch)
+}
+
+//Collect reads the alerts raised since the last poll and gathers the counters
+func (c *AlertsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	eachAccount(ch, c.targets, "alerts", alertsErrorDesc, c.collectAccount)
+	c.alerts.Collect(ch)
+}
+
+//collectAccount counts the settled alerts of account raised since its high-water mark and records the health of the poll
+func (c *AlertsCollector) collectAccount(ch chan<- prometheus.Metric, client *twilioClient, account AccountRecords) {
+	if !ownAccount("alerts", client, account.Sid) {
+		return
+	}
+	start := time.Now()
+	cutoff := start.Add(-c.settle)
+	mark, ok := c.marks[account.Sid]
+	if !ok {
+		mark = newHighWaterMark(cutoff)
+		c.marks[account.Sid] = mark
+	}
+
+	query := url.Values{"StartDate": {mark.at.UTC().Format(time.RFC3339)}, "EndDate": {cutoff.UTC().Format(time.RFC3339)}}
+	fresh, err := client.readSince(client.v1ListURL(client.monitorURL, "/Alerts", query), mark, cutoff, decodeAlerts)
+	for _, record := range fresh {
+		mark.advance(record.sid, record.created)
+		alert := record.value.(AlertRecords)
+		c.alerts.WithLabelValues(account.Sid, alert.ErrorCode, alert.LogLevel, resourceType(alert.ResourceSid), errorDescription(alert.ErrorCode)).Inc()
+	}
+	if err != nil {
+		reportError(ch, alertsErrorDesc, fmt.Errorf("reading alerts of %s: %w", account.Sid, err))
+	}
+	recordPoll(account.Sid, "alerts", start, err)
+}
+
+//decodeAlerts decodes a page of the Alerts list for readSince
+func decodeAlerts(body []byte) ([]listedRecord, error) {
+	var bodyObject Alerts
+	if err := json.Unmarshal(body, &bodyObject); err != nil {
+		return nil, err
+	}
+	records := make([]listedRecord, 0, len(bodyObject.Alerts))
+	for _, alert := range bodyObject.Alerts {
+		created, err := time.Parse(time.RFC3339, alert.DateCreated)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, listedRecord{sid: alert.Sid, created: created, value: alert})
+	}
+	return records, nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	accountSidRE = regexp.MustCompile(`^AC[0-9a-f]{32}$`)
+	apiKeySidRE  = regexp.MustCompile(`^SK[0-9a-f]{32}$`)
+	secretRE     = regexp.MustCompile(`^[0-9A-Za-z]{32}$`)
+)
+
+//Credentials are what twil authenticates to Twilio with, the Account SID is always needed and is paired with either its Auth Token or an API Key SID and Secret
+type Credentials struct {
+	AccountSid string
+	AuthToken  string
+	APIKey     string
+	APISecret  string
+}
+
+//Kind names the type of credential in use, safe to log
+func (c Credentials) Kind() string {
+	if c.APIKey != "" {
+		return "API Key " + c.APIKey
+	}
+	return "Auth Token"
+}
+
+//BasicAuth returns the HTTP basic auth username and password for the credentials
+func (c Credentials) BasicAuth() (string, string) {
+	if c.APIKey != "" {
+		return c.APIKey, c.APISecret
+	}
+	return c.AccountSid, c.AuthToken
+}
+
+//Validate checks the credentials are well formed before they are sent to Twilio, errors never include secret values
+func (c Credentials) Validate() error {
+	if !accountSidRE.MatchString(c.AccountSid) {
+		return fmt.Errorf("account SID %q is malformed, expected AC followed by 32 hex characters", c.AccountSid)
+	}
+
+	if c.APIKey != "" || c.APISecret != "" {
+		if c.AuthToken != "" {
+			return fmt.Errorf("both an Auth Token and an API Key were given, use only one")
+		}
+		if !apiKeySidRE.MatchString(c.APIKey) {
+			return fmt.Errorf("API Key SID %q is malformed, expected SK followed by 32 hex characters", c.APIKey)
+		}
+		if !secretRE.MatchString(c.APISecret) {
+			return fmt.Errorf("API Key Secret is malformed, expected 32 alphanumeric characters")
+		}
+		return nil
+	}
+
+	if c.AuthToken == "" {
+		return fmt.Errorf("no Auth Token or API Key given for account %s", c.AccountSid)
+	}
+	if !secretRE.MatchString(c.AuthToken) {
+		if decoded, err := base64.StdEncoding.DecodeString(c.AuthToken); err == nil && strings.HasPrefix(string(decoded), "AC") {
+			return fmt.Errorf("Auth Token looks like a base64 encoded SID:token pair, pass the raw Auth Token instead")
+		}
+		return fmt.Errorf("Auth Token is malformed, expected 32 alphanumeric characters")
+	}
+	return nil
+}
+
+//secretFile is a secret kept in a file, such as a mounted Kubernetes or Docker secret, and re-read whenever the file changes
+type secretFile struct {
+	path    string
+	modTime time.Time
+	size    int64
+	value   string
+}
+
+//read returns the current contents of the file without surrounding whitespace and whether they changed since the last read
+func (f *secretFile) read() (string, bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return f.value, false, err
+	}
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.value, false, nil
+	}
+
+	body, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return f.value, false, err
+	}
+	value := strings.TrimSpace(string(body))
+	changed := value != f.value
+	f.modTime, f.size, f.value = info.ModTime(), info.Size(), value
+	return value, changed, nil
+}
+
+//credentialSource hands out Credentials, picking up rotated Auth Tokens and API Secrets from their files without a restart
+type credentialSource struct {
+	mu         sync.Mutex
+	current    Credentials
+	tokenFile  *secretFile
+	secretFile *secretFile
+}
+
+//newCredentialSource builds a source from static credentials, tokenPath and secretPath when set replace the Auth Token and API Secret
+func newCredentialSource(static Credentials, tokenPath string, secretPath string) (*credentialSource, error) {
+	s := &credentialSource{current: static}
+	if tokenPath != "" {
+		if static.AuthToken != "" {
+			return nil, fmt.Errorf("an Auth Token and an Auth Token file were both given, use only one")
+		}
+		s.tokenFile = &secretFile{path: tokenPath}
+	}
+	if secretPath != "" {
+		if static.APISecret != "" {
+			return nil, fmt.Errorf("an API Secret and an API Secret file were both given, use only one")
+		}
+		s.secretFile = &secretFile{path: secretPath}
+	}
+
+	credentials, err := s.Credentials()
+	if err != nil {
+		return nil, err
+	}
+	return s, credentials.Validate()
+}
+
+//Credentials returns the latest credentials, re-reading secret files that changed on disk. A rotated secret that fails validation is reported and the previous one is kept.
+func (s *credentialSource) Credentials() (Credentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := s.current
+	changed := false
+	for _, f := range []struct {
+		file  *secretFile
+		value *string
+	}{{s.tokenFile, &next.AuthToken}, {s.secretFile, &next.APISecret}} {
+		if f.file == nil {
+			continue
+		}
+		value, fileChanged, err := f.file.read()
+		if err != nil {
+			return s.current, err
+		}
+		if fileChanged {
+			*f.value = value
+			changed = true
+		}
+	}
+
+	if changed {
+		if s.current.AuthToken != "" || s.current.APISecret != "" {
+			if err := next.Validate(); err != nil {
+				return s.current, fmt.Errorf("ignoring rotated secret: %v", err)
+			}
+			log.Printf("reloaded %s for account %s", next.Kind(), next.AccountSid)
+		}
+		s.current = next
+	}
+	return s.current, nil
+}
+
+//AccountSid is the SID of the account the credentials belong to
+func (s *credentialSource) AccountSid() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current.AccountSid
+}
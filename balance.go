@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//Balance is the object from Twilios Balance api
+type Balance struct {
+	AccountSid string  `json:"account_sid"`
+	Balance    float64 `json:"balance,string"`
+	Currency   string  `json:"currency"`
+}
+
+//fetchBalance returns the current balance of account
+func (t *twilioClient) fetchBalance(account string) (Balance, error) {
+	var balance Balance
+	err := t.getJSON(t.baseURL+"/2010-04-01/Accounts/"+account+"/Balance.json", &balance)
+	return balance, err
+}
+
+//BalanceCollector exports the prepaid balance of every account
+type BalanceCollector struct {
+	targets []accountTarget
+	balance *prometheus.Desc
+}
+
+//newBalanceCollector creates a collector for the balance of targets
+func newBalanceCollector(targets []accountTarget) *BalanceCollector {
+	return &BalanceCollector{
+		targets: targets,
+		balance: prometheus.NewDesc("twil_account_balance", "Current account balance", []string{"account_sid", "account_name", "currency"}, nil),
+	}
+}
+
+//Describe initializes channels used to pull Metrics
+func (c *BalanceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.balance
+}
+
+//Collect gathers the metrics
+func (c *BalanceCollector) Collect(ch chan<- prometheus.Metric) {
+	eachAccount(ch, c.targets, "balance", c.balance, c.collectAccount)
+}
+
+//collectAccount emits the balance of account and records the health of the poll
+func (c *BalanceCollector) collectAccount(ch chan<- prometheus.Metric, client *twilioClient, account AccountRecords) {
+	start := time.Now()
+	balance, err := client.fetchBalance(account.Sid)
+	if err != nil {
+		reportError(ch, c.balance, fmt.Errorf("fetching balance of %s: %w", account.Sid, err))
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.balance, prometheus.GaugeValue, balance.Balance, account.Sid, account.FriendlyName, balance.Currency)
+	}
+	recordPoll(account.Sid, "balance", start, err)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//Calls is the outside object from Twilios Calls api
+type Calls struct {
+	Calls       []CallRecords `json:"calls"`
+	NextPageURI string        `json:"next_page_uri"`
+}
+
+//CallRecords are the inside objects from Twilios Calls api
+type CallRecords struct {
+	Sid         string  `json:"sid"`
+	AccountSid  string  `json:"account_sid"`
+	DateCreated string  `json:"date_created"`
+	Status      string  `json:"status"`
+	Direction   string  `json:"direction"`
+	Duration    string  `json:"duration"`
+	Price       *string `json:"price"`
+	PriceUnit   string  `json:"price_unit"`
+}
+
+//finalCallStatuses are the statuses a call ends in
+var finalCallStatuses = map[string]bool{
+	"completed": true,
+	"busy":      true,
+	"no-answer": true,
+	"failed":    true,
+	"canceled":  true,
+}
+
+//callPriceWait is how long an ended call is re-read waiting for Twilio to price it
+const callPriceWait = 30 * time.Minute
+
+//callPendingLimit is how long after its creation a call is re-read at all, a call that hasn't ended by then is counted under its last status and dropped.
+//It bounds how far back of the high-water mark the Calls list is read, however long calls stay queued, ringing or in progress.
+const callPendingLimit = 2 * callPriceWait
+
+//pendingCall is a call that was created but hasn't been fully counted yet, either because it hasn't ended or because it hasn't been priced
+type pendingCall struct {
+	created time.Time
+	since   time.Time
+	counted bool
+	last    CallRecords
+}
+
+//CallsCollector counts calls by outcome and observes their duration and price, reading /Calls.json incrementally from a high-water mark on DateCreated.
+//Calls still in progress, and ended calls Twilio hasn't priced yet, are re-read on later polls by reading the list back to the oldest of them, not one request per call, for at most callPendingLimit.
+type CallsCollector struct {
+	targets  []accountTarget
+	calls    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	price    *prometheus.HistogramVec
+
+	mu      sync.Mutex
+	marks   map[string]*highWaterMark
+	pending map[string]map[string]*pendingCall
+}
+
+//newCallsCollector creates a collector counting calls of targets created from now on
+func newCallsCollector(targets []accountTarget) *CallsCollector {
+	return &CallsCollector{
+		targets: targets,
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "twil_calls_total",
+			Help: "Calls ended since twil started, by final status and direction, calls that never end are counted under their last status",
+		}, []string{"account_sid", "status", "direction"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "twil_call_duration_seconds",
+			Help:    "Duration of completed calls",
+			Buckets: prometheus.ExponentialBuckets(5, 2, 11),
+		}, []string{"account_sid", "direction"}),
+		price: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "twil_call_price",
+			Help:    "Price charged per call, in price_unit",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 12),
+		}, []string{"account_sid", "direction", "price_unit"}),
+		marks:   map[string]*highWaterMark{},
+		pending: map[string]map[string]*pendingCall{},
+	}
+}
+
+//callsErrorDesc identifies the invalid metrics of failed call polls
+var callsErrorDesc = prometheus.NewDesc("twil_calls_total", "Calls ended since twil started", nil, nil)
+
+//Describe initializes channels used to pull Metrics
+func (c *CallsCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.calls.Describe(ch)
+	c.duration.Describe(ch)
+	c.price.Describe(ch)
+}
+
+//Collect reads the calls created or ended since the last poll and gathers the metrics
+func (c *CallsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	eachAccount(ch, c.targets, "calls", callsErrorDesc, c.collectAccount)
+	c.calls.Collect(ch)
+	c.duration.Collect(ch)
+	c.price.Collect(ch)
+}
+
+//collectAccount reads the calls of account back to its high-water mark or its oldest pending call, counting new and pending ones, and records the health of the poll.
+//A failed read changes nothing, so throttling never drops a call before it is counted, and a read cut short by max_pages skips ahead like readSince.
+func (c *CallsCollector) collectAccount(ch chan<- prometheus.Metric, client *twilioClient, account AccountRecords) {
+	start := time.Now()
+	mark, ok := c.marks[account.Sid]
+	if !ok {
+		mark = newHighWaterMark(start)
+		c.marks[account.Sid] = mark
+		c.pending[account.Sid] = map[string]*pendingCall{}
+	}
+	pending := c.pending[account.Sid]
+
+	since := mark.at
+	for sid, p := range pending {
+		if start.Sub(p.created) > callPendingLimit {
+			c.expire(account.Sid, sid, p)
+			delete(pending, sid)
+			continue
+		}
+		if p.created.Before(since) {
+			since = p.created
+		}
+	}
+	records, err := client.readBack(client.listURL("/Accounts/"+account.Sid+"/Calls.json", nil), since, decodeCalls)
+	complete := err == nil
+	if errors.Is(err, errPageLimit) {
+		err = skipAhead(mark, records, start, err)
+	} else if err != nil {
+		reportError(ch, callsErrorDesc, fmt.Errorf("reading calls of %s: %w", account.Sid, err))
+		recordPoll(account.Sid, "calls", start, err)
+		return
+	}
+
+	listed := make(map[string]bool, len(records))
+	for _, record := range records {
+		listed[record.sid] = true
+		call := record.value.(CallRecords)
+		if p, ok := pending[record.sid]; ok {
+			p.last = call
+			if c.observe(account.Sid, call, p) {
+				delete(pending, record.sid)
+			}
+			continue
+		}
+		if record.created.After(start) || !mark.isNew(record.sid, record.created) {
+			continue
+		}
+		mark.advance(record.sid, record.created)
+		p := &pendingCall{created: record.created, since: start, last: call}
+		if !c.observe(account.Sid, call, p) {
+			pending[record.sid] = p
+		}
+	}
+	for sid := range pending {
+		//gone from a complete read of the list, the call was deleted and there is nothing left to count.
+		//After a read cut short by max_pages it may just be past the pages read, so it is kept until callPendingLimit.
+		if complete && !listed[sid] {
+			delete(pending, sid)
+		}
+	}
+	if err != nil {
+		reportError(ch, callsErrorDesc, fmt.Errorf("reading calls of %s: %w", account.Sid, err))
+	}
+	recordPoll(account.Sid, "calls", start, err)
+}
+
+//expire gives up on the pending call sid after callPendingLimit, counting it under its last status if it never ended
+func (c *CallsCollector) expire(account string, sid string, p *pendingCall) {
+	if p.counted {
+		return
+	}
+	log.Printf("call %s of %s hasn't ended after %s, counting it as %s", sid, account, callPendingLimit, p.last.Status)
+	c.calls.WithLabelValues(account, p.last.Status, p.last.Direction).Inc()
+}
+
+//observe counts call once it has ended and observes its price once Twilio has priced it, reporting whether the call is fully counted
+func (c *CallsCollector) observe(account string, call CallRecords, p *pendingCall) bool {
+	if !finalCallStatuses[call.Status] {
+		return false
+	}
+	if !p.counted {
+		p.counted = true
+		c.calls.WithLabelValues(account, call.Status, call.Direction).Inc()
+		if duration, err := strconv.ParseFloat(call.Duration, 64); err == nil && call.Status == "completed" {
+			c.duration.WithLabelValues(account, call.Direction).Observe(duration)
+		}
+	}
+	if call.Price != nil && *call.Price != "" {
+		if price, err := strconv.ParseFloat(*call.Price, 64); err == nil {
+			//Twilio reports charges as negative amounts
+			c.price.WithLabelValues(account, call.Direction, call.PriceUnit).Observe(math.Abs(price))
+		}
+		return true
+	}
+	return call.Status != "completed" || time.Since(p.since) > callPriceWait
+}
+
+//decodeCalls decodes a page of the Calls list for readSince
+func decodeCalls(body []byte) ([]listedRecord, error) {
+	var bodyObject Calls
+	if err := json.Unmarshal(body, &bodyObject); err != nil {
+		return nil, err
+	}
+	records := make([]listedRecord, 0, len(bodyObject.Calls))
+	for _, call := range bodyObject.Calls {
+		created, err := time.Parse(twilioTime, call.DateCreated)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, listedRecord{sid: call.Sid, created: created, value: call})
+	}
+	return records, nil
+}
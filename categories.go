@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+)
+
+//Category maps a Twilio usage category to the metric name and help text it is exported with
+type Category struct {
+	Category string `json:"category"`
+	Metric   string `json:"metric"`
+	Help     string `json:"help"`
+}
+
+//builtinCategories is the catalog of usage categories twil knows about out of the box
+var builtinCategories = []Category{
+	{"callerIDLookups", "twil_callerIDLookups", "Total CallerID Lookups"},
+	{"calls", "twil_calls", "Total Call Minutes"},
+	{"calls-client", "twil_calls_client", "Total Client Call Minutes"},
+	{"calls-sip", "twil_calls_sip", "SIP Minutes"},
+	{"calls-inbound", "twil_calls_inbound", "Inbound Voice Minutes"},
+	{"calls-inbound-local", "twil_calls_inbound_local", "Inbound Local Calls"},
+	{"calls-inbound-mobile", "twil_calls_mobile", "Inbound Mobile Calls"},
+	{"calls-inbound-tollfree", "twil_calls_tollfree", "Inbound Toll Free Calls"},
+	{"calls-outbound", "twil_calls_outbound", "Outbound Voice Minutes"},
+	{"phonenumbers", "twil_phonenumbers", "Phone Numbers"},
+	{"phonenumbers-mobile", "twil_phonenumbers_mobile", "Mobile Phone Numbers"},
+	{"phonenumbers-local", "twil_phonenumbers_local", "Local Phone Numbers"},
+	{"phonenumbers-tollfree", "twil_phonenumbers_tollfree", "Toll Free Phone Numbers"},
+	{"shortcodes", "twil_shortcodes", "Short Codes"},
+	{"shortcodes-customerowned", "twil_shortcodes_customer_owned", "Customer Owned Short Codes"},
+	{"shortcodes-random", "twil_shortcodes_random", "Random Short Codes"},
+	{"shortcodes-vanity", "twil_shortcodes_vanity", "Vanity Short Codes"},
+	{"sms", "twil_sms", "SMS"},
+	{"sms-inbound", "twil_sms_inbound", "Inbound SMS"},
+	{"sms-inbound-longcode", "twil_sms_inbound_standard", "Standard Inbound SMS"},
+	{"sms-inbound-shortcode", "twil_sms_inbound_shortcode", "Short Code Inbound SMS"},
+	{"sms-outbound", "twil_sms_outbound", "Outbound SMS"},
+	{"sms-outbound-longcode", "twil_sms_outbound_standard", "Standard Outbound SMS"},
+	{"sms-outbound-shortcode", "twil_sms_outbound_shortcode", "Short Code Outbound SMS"},
+	{"mms", "twil_mms", "MMS"},
+	{"mms-inbound", "twil_mms_inbound", "Inbound MMS"},
+	{"mms-inbound-longcode", "twil_mms_inbound_standard", "Standard Inbound MMS"},
+	{"mms-inbound-shortcode", "twil_mms_inbound_shortcode", "Short Code Inbound MMS"},
+	{"mms-outbound", "twil_mms_outbound", "Outbound MMS"},
+	{"mms-outbound-longcode", "twil_mms_outbound_standard", "Standard Outbound MMS"},
+	{"mms-outbound-shortcode", "twil_mms_outbound_shortcode", "Short Code Outbound MMS"},
+	{"recordings", "twil_recordings", "Recordings"},
+	{"recordingstorage", "twil_recordings_storage", "Recordings Storage"},
+	{"transcriptions", "twil_transcriptions", "Transcriptions"},
+	{"mediastorage", "twil_mediastorage", "Media Storage"},
+	{"authy-sms-outbound", "twil_authy_sms_outbound", "Authy/Verify Outbound SMS Messages"},
+	{"authy-calls-outbound", "twil_authy_calls_outbound", "Authy/Verify Outbound Calls"},
+	{"authy-authentications", "twil_authy_authentications", "Authy Authentications"},
+	{"authy-phone-verifications", "twil_authy_phone_verifications", "Verify"},
+	{"authy-phone-intelligence", "twil_authy_phone_intelligence", "Authy Phone Intelligence Requests"},
+	{"authy-monthly-fees", "twil_authy_monthly_fees", "Authy Monthly Fees"},
+	{"monitor-storage", "twil_monitor_storage", "Monitor Events Storage"},
+	{"monitor-reads", "twil_monitor_reads", "Monitor Events API Reads"},
+	{"monitor-write", "twil_monitor_writes", "Monitor Events API Writes"},
+	{"taskrouter-tasks", "twil_task_router_tasks", "Task Router Tasks Created"},
+	{"turnmegabytes", "twil_turn_megabytes", "TURN Megabytes"},
+	{"calls-recordings", "twil_call_recordings", "Call Recordings"},
+	{"trunking-recordings", "twil_trunking_recordings", "Trunking Recordings"},
+	{"trunking-termination", "twil_trunking_termination", "Trunking Termination Minutes"},
+	{"trunking-origination", "twil_trunking_origination", "Trunking Origination Minutes"},
+}
+
+//metricNameRE matches valid Prometheus metric names
+var metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+//loadCategories returns the built-in catalog merged with the JSON array of categories in path, entries in the file win over built-ins
+func loadCategories(path string) ([]Category, error) {
+	categories := append([]Category(nil), builtinCategories...)
+	if path == "" {
+		return categories, nil
+	}
+
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var extra []Category
+	if err := json.Unmarshal(body, &extra); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	index := make(map[string]int, len(categories))
+	for i, cat := range categories {
+		index[cat.Category] = i
+	}
+	for i, cat := range extra {
+		if cat.Category == "" || cat.Metric == "" {
+			return nil, fmt.Errorf("%s: entry %d needs both category and metric", path, i)
+		}
+		if cat.Help == "" {
+			cat.Help = cat.Category
+		}
+		if j, ok := index[cat.Category]; ok {
+			categories[j] = cat
+			continue
+		}
+		index[cat.Category] = len(categories)
+		categories = append(categories, cat)
+	}
+
+	return categories, validateCategories(categories)
+}
+
+//validateCategories checks every metric name is valid and that no two categories, including the generic usage metrics, export the same name
+func validateCategories(categories []Category) error {
+	metrics := map[string]string{
+		"twil_usage_count":  "the twil_usage_* family",
+		"twil_usage_amount": "the twil_usage_* family",
+		"twil_usage_price":  "the twil_usage_* family",
+	}
+	for _, cat := range categories {
+		if !metricNameRE.MatchString(cat.Metric) {
+			return fmt.Errorf("category %q: invalid metric name %q", cat.Category, cat.Metric)
+		}
+		for _, name := range []string{cat.Metric, cat.Metric + "_usage", cat.Metric + "_price"} {
+			if other, ok := metrics[name]; ok {
+				return fmt.Errorf("category %q: metric %q is already used by %s", cat.Category, name, other)
+			}
+			metrics[name] = fmt.Sprintf("category %q", cat.Category)
+		}
+	}
+	return nil
+}
@@ -1,341 +1,224 @@
-package main
-
-import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"net/http"
-	"time"
-
-	"github.com/prometheus/client_golang/prometheus"
-)
-
-//Usage is the outside object from Twilios Usage api
-type Usage struct {
-	FirstPageURI    string         `json:"first_page_uri"`
-	End             int            `json:"end"`
-	PreviousPageURI interface{}    `json:"previous_page_uri"`
-	URI             string         `json:"uri"`
-	PageSize        int            `json:"page_size"`
-	Start           int            `json:"start"`
-	UsageRecords    []UsageRecords `json:"usage_records"`
-	NextPageURI     string         `json:"next_page_uri"`
-	Page            int            `json:"page"`
-}
-
-//UsageRecords are the inside objects from Twilios Usage api
-type UsageRecords struct {
-	Category    string    `json:"category"`
-	Description string    `json:"description"`
-	AccountSid  string    `json:"account_sid"`
-	StartDate   string    `json:"start_date"`
-	EndDate     string    `json:"end_date"`
-	AsOf        time.Time `json:"as_of"`
-	Count       float64   `json:",string"`
-	CountUnit   string    `json:"count_unit"`
-	Usage       float64   `json:",string"`
-	UsageUnit   string    `json:"usage_unit"`
-	Price       float64   `json:",string"`
-	PriceUnit   string    `json:"price_unit"`
-	APIVersion  string    `json:"api_version"`
-	URI         string    `json:"uri"`
-}
-
-//UsageCollector creates the base Description objects for Prometheus Metrics
-type UsageCollector struct {
-	callerIDLookups         *prometheus.Desc
-	calls                   *prometheus.Desc
-	callsClient             *prometheus.Desc
-	callsSip                *prometheus.Desc
-	callsInbound            *prometheus.Desc
-	callsInboundLocal       *prometheus.Desc
-	callsInboundMobile      *prometheus.Desc
-	callsInboundTollFree    *prometheus.Desc
-	callsOutbound           *prometheus.Desc
-	phoneNumbers            *prometheus.Desc
-	phoneNumbersMobile      *prometheus.Desc
-	phoneNumbersLocal       *prometheus.Desc
-	phoneNumbersTollFree    *prometheus.Desc
-	shortCodes              *prometheus.Desc
-	shortCodesCustomerOwned *prometheus.Desc
-	shortCodesRandom        *prometheus.Desc
-	shortCodesVanity        *prometheus.Desc
-	sms                     *prometheus.Desc
-	smsInbound              *prometheus.Desc
-	smsInboundLongCode      *prometheus.Desc
-	smsInboundShortCode     *prometheus.Desc
-	smsOutbound             *prometheus.Desc
-	smsOutboundLongCode     *prometheus.Desc
-	smsOutboundShortCode    *prometheus.Desc
-	mms                     *prometheus.Desc
-	mmsInbound              *prometheus.Desc
-	mmsInboundLongCode      *prometheus.Desc
-	mmsInboundShortCode     *prometheus.Desc
-	mmsOutbound             *prometheus.Desc
-	mmsOutboundLongCode     *prometheus.Desc
-	mmsOutboundShortCode    *prometheus.Desc
-	recordings              *prometheus.Desc
-	recordingsStorage       *prometheus.Desc
-	transcriptions          *prometheus.Desc
-	mediaStorage            *prometheus.Desc
-	authySMSOutbound        *prometheus.Desc
-	authyCallsOutbound      *prometheus.Desc
-	authyAuthentications    *prometheus.Desc
-	authyPhoneVerifications *prometheus.Desc
-	authyPhoneIntelligence  *prometheus.Desc
-	authyMonthlyFees        *prometheus.Desc
-	monitorStorage          *prometheus.Desc
-	monitorReads            *prometheus.Desc
-	monitorWrites           *prometheus.Desc
-	taskRouterTasks         *prometheus.Desc
-	turnMegabytes           *prometheus.Desc
-	callRecordings          *prometheus.Desc
-	trunkingRecordings      *prometheus.Desc
-	trunkingTermination     *prometheus.Desc
-	trunkingOrigination     *prometheus.Desc
-}
-
-//newUsageCollector initializes the collectors and assigns fqName and help description for exported metrics
-func newUsageCollector() *UsageCollector {
-	return &UsageCollector{
-		callerIDLookups:         prometheus.NewDesc("twil_callerIDLookups", "Total CallerID Lookups", nil, nil),
-		calls:                   prometheus.NewDesc("twil_calls", "Total Call Minutes", nil, nil),
-		callsClient:             prometheus.NewDesc("twil_calls_client", "Total Client Call Minutes", nil, nil),
-		callsSip:                prometheus.NewDesc("twil_calls_sip", "SIP Minutes", nil, nil),
-		callsInbound:            prometheus.NewDesc("twil_calls_inbound", "Inbound Voice Minutes", nil, nil),
-		callsInboundLocal:       prometheus.NewDesc("twil_calls_inbound_local", "Inbound Local Calls", nil, nil),
-		callsInboundMobile:      prometheus.NewDesc("twil_calls_mobile", "Inbound Mobile Calls", nil, nil),
-		callsInboundTollFree:    prometheus.NewDesc("twil_calls_tollfree", "Inbound Toll Free Calls", nil, nil),
-		callsOutbound:           prometheus.NewDesc("twil_calls_outbound", "Outbound Voice Minutes", nil, nil),
-		phoneNumbers:            prometheus.NewDesc("twil_phonenumbers", "Phone Numbers", nil, nil),
-		phoneNumbersMobile:      prometheus.NewDesc("twil_phonenumbers_mobile", "Mobile Phone Numbers", nil, nil),
-		phoneNumbersLocal:       prometheus.NewDesc("twil_phonenumbers_local", "Local Phone Numbers", nil, nil),
-		phoneNumbersTollFree:    prometheus.NewDesc("twil_phonenumbers_tollfree", "Toll Free Phone Numbers", nil, nil),
-		shortCodes:              prometheus.NewDesc("twil_shortcodes", "Short Codes", nil, nil),
-		shortCodesCustomerOwned: prometheus.NewDesc("twil_shortcodes_customer_owned", "Customer Owned Short Codes", nil, nil),
-		shortCodesRandom:        prometheus.NewDesc("twil_shortcodes_random", "Random Short Codes", nil, nil),
-		shortCodesVanity:        prometheus.NewDesc("twil_shortcodes_vanity", "Vanity Short Codes", nil, nil),
-		sms:                     prometheus.NewDesc("twil_sms", "SMS", nil, nil),
-		smsInbound:              prometheus.NewDesc("twil_sms_inbound", "Inbound SMS", nil, nil),
-		smsInboundLongCode:      prometheus.NewDesc("twil_sms_inbound_standard", "Standard Inbound SMS", nil, nil),
-		smsInboundShortCode:     prometheus.NewDesc("twil_sms_inbound_shortcode", "Short Code Inbound SMS", nil, nil),
-		smsOutbound:             prometheus.NewDesc("twil_sms_outbound", "Outbound SMS", nil, nil),
-		smsOutboundLongCode:     prometheus.NewDesc("twil_sms_outbound_standard", "Standard Outbound SMS", nil, nil),
-		smsOutboundShortCode:    prometheus.NewDesc("twil_sms_outbound_shortcode", "Short Code Outbound SMS", nil, nil),
-		mms:                     prometheus.NewDesc("twil_mms", "MMS", nil, nil),
-		mmsInbound:              prometheus.NewDesc("twil_mms_inbound", "Inbound MMS", nil, nil),
-		mmsInboundLongCode:      prometheus.NewDesc("twil_mms_inbound_standard", "Standard Inbound MMS", nil, nil),
-		mmsInboundShortCode:     prometheus.NewDesc("twil_mms_inbound_shortcode", "Short Code Inbound MMS", nil, nil),
-		mmsOutbound:             prometheus.NewDesc("twil_mms_outbound", "Outbound MMS", nil, nil),
-		mmsOutboundLongCode:     prometheus.NewDesc("twil_mms_outbound_standard", "Standard Outbound MMS", nil, nil),
-		mmsOutboundShortCode:    prometheus.NewDesc("twil_mms_outbound_shortcode", "Short Code Outbound MMS", nil, nil),
-		recordings:              prometheus.NewDesc("twil_recordings", "Recordings", nil, nil),
-		recordingsStorage:       prometheus.NewDesc("twil_recordings_storage", "Recordings Storage", nil, nil),
-		transcriptions:          prometheus.NewDesc("twil_transcriptions", "Transcriptions", nil, nil),
-		mediaStorage:            prometheus.NewDesc("twil_mediastorage", "Media Storage", nil, nil),
-		authySMSOutbound:        prometheus.NewDesc("twil_authy_sms_outbound", "Authy/Verify Outbound SMS Messages", nil, nil),
-		authyCallsOutbound:      prometheus.NewDesc("twil_authy_calls_outbound", "Authy/Verify Outbound Calls", nil, nil),
-		authyAuthentications:    prometheus.NewDesc("twil_authy_authentications", "Authy Authentications", nil, nil),
-		authyPhoneVerifications: prometheus.NewDesc("twil_authy_phone_verifications", "Verify", nil, nil),
-		authyPhoneIntelligence:  prometheus.NewDesc("twil_authy_phone_intelligence", "Authy Phone Intelligence Requests", nil, nil),
-		authyMonthlyFees:        prometheus.NewDesc("twil_authy_monthly_fees", "Authy Monthly Fees", nil, nil),
-		monitorStorage:          prometheus.NewDesc("twil_monitor_storage", "Monitor Events Storage", nil, nil),
-		monitorReads:            prometheus.NewDesc("twil_monitor_reads", "Monitor Events API Reads", nil, nil),
-		monitorWrites:           prometheus.NewDesc("twil_monitor_writes", "Monitor Events API Writes", nil, nil),
-		taskRouterTasks:         prometheus.NewDesc("twil_task_router_tasks", "Task Router Tasks Created", nil, nil),
-		turnMegabytes:           prometheus.NewDesc("twil_turn_megabytes", "TURN Megabytes", nil, nil),
-		callRecordings:          prometheus.NewDesc("twil_call_recordings", "Call Recordings", nil, nil),
-		trunkingRecordings:      prometheus.NewDesc("twil_trunking_recordings", "Trunking Recordings", nil, nil),
-		trunkingTermination:     prometheus.NewDesc("twil_trunking_termination", "Trunking Termination Minutes", nil, nil),
-		trunkingOrigination:     prometheus.NewDesc("twil_trunking_origination", "Trunking Origination Minutes", nil, nil),
-	}
-}
-
-//Describe initializes channels used to pull Metrics
-func (c *UsageCollector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- c.callerIDLookups
-	ch <- c.calls
-	ch <- c.callsClient
-	ch <- c.callsSip
-	ch <- c.callsInbound
-	ch <- c.callsInboundLocal
-	ch <- c.callsInboundMobile
-	ch <- c.callsInboundTollFree
-	ch <- c.callsOutbound
-	ch <- c.phoneNumbers
-	ch <- c.phoneNumbersMobile
-	ch <- c.phoneNumbersLocal
-	ch <- c.phoneNumbersTollFree
-	ch <- c.shortCodes
-	ch <- c.shortCodesCustomerOwned
-	ch <- c.shortCodesRandom
-	ch <- c.shortCodesVanity
-	ch <- c.sms
-	ch <- c.smsInbound
-	ch <- c.smsInboundLongCode
-	ch <- c.smsInboundShortCode
-	ch <- c.smsOutbound
-	ch <- c.smsOutboundLongCode
-	ch <- c.smsOutboundShortCode
-	ch <- c.mms
-	ch <- c.mmsInbound
-	ch <- c.mmsInboundLongCode
-	ch <- c.mmsInboundShortCode
-	ch <- c.mmsOutbound
-	ch <- c.mmsOutboundLongCode
-	ch <- c.mmsOutboundShortCode
-	ch <- c.recordings
-	ch <- c.recordingsStorage
-	ch <- c.transcriptions
-	ch <- c.mediaStorage
-	ch <- c.authySMSOutbound
-	ch <- c.authyCallsOutbound
-	ch <- c.authyAuthentications
-	ch <- c.authyPhoneVerifications
-	ch <- c.authyPhoneIntelligence
-	ch <- c.authyMonthlyFees
-	ch <- c.monitorStorage
-	ch <- c.monitorReads
-	ch <- c.monitorWrites
-	ch <- c.taskRouterTasks
-	ch <- c.turnMegabytes
-	ch <- c.callRecordings
-	ch <- c.trunkingRecordings
-	ch <- c.trunkingTermination
-	ch <- c.trunkingOrigination
-}
-
-//Collect gathers the metrics
-func (c *UsageCollector) Collect(ch chan<- prometheus.Metric) {
-
-	client := http.Client{}
-
-	reqURL := "https://api.twilio.com/2010-04-01/Accounts/" + *Account + "/Usage/Records.json"
-	method := "GET"
-
-	req, err := http.NewRequest(method, reqURL, nil)
-
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	formattedToken := "Basic " + *Token
-	req.Header.Add("Authorization", formattedToken)
-	req.Header.Add("User-Agent", "twil")
-
-	res, err := client.Do(req)
-	if err != nil {
-		fmt.Println(err)
-	}
-	defer res.Body.Close()
-	body, err := ioutil.ReadAll(res.Body)
-
-	var bodyObject Usage
-	json.Unmarshal(body, &bodyObject)
-
-	for k := range bodyObject.UsageRecords {
-		switch {
-		case bodyObject.UsageRecords[k].Category == "callerIDLookups":
-			ch <- prometheus.MustNewConstMetric(c.callerIDLookups, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "calls":
-			ch <- prometheus.MustNewConstMetric(c.calls, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "calls-client":
-			ch <- prometheus.MustNewConstMetric(c.callsClient, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "calls-sip":
-			ch <- prometheus.MustNewConstMetric(c.callsSip, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "calls-inbound":
-			ch <- prometheus.MustNewConstMetric(c.callsInbound, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "calls-inbound-local":
-			ch <- prometheus.MustNewConstMetric(c.callsInboundLocal, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "calls-inbound-mobile":
-			ch <- prometheus.MustNewConstMetric(c.callsInboundMobile, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "calls-inbound-tollfree":
-			ch <- prometheus.MustNewConstMetric(c.callsInboundTollFree, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "calls-outbound":
-			ch <- prometheus.MustNewConstMetric(c.callsOutbound, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "phonenumbers":
-			ch <- prometheus.MustNewConstMetric(c.phoneNumbers, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "phonenumbers-mobile":
-			ch <- prometheus.MustNewConstMetric(c.phoneNumbersMobile, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "phonenumbers-local":
-			ch <- prometheus.MustNewConstMetric(c.phoneNumbersLocal, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "phonenumbers-tollfree":
-			ch <- prometheus.MustNewConstMetric(c.phoneNumbersTollFree, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "shortcodes":
-			ch <- prometheus.MustNewConstMetric(c.shortCodes, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "shortcodes-customerowned":
-			ch <- prometheus.MustNewConstMetric(c.shortCodesCustomerOwned, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "shortcodes-random":
-			ch <- prometheus.MustNewConstMetric(c.shortCodesRandom, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "shortcodes-vanity":
-			ch <- prometheus.MustNewConstMetric(c.shortCodesVanity, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "sms":
-			ch <- prometheus.MustNewConstMetric(c.sms, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "sms-inbound":
-			ch <- prometheus.MustNewConstMetric(c.smsInbound, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "sms-inbound-longcode":
-			ch <- prometheus.MustNewConstMetric(c.smsInboundLongCode, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "sms-inbound-shortcode":
-			ch <- prometheus.MustNewConstMetric(c.smsInboundShortCode, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "sms-outbound":
-			ch <- prometheus.MustNewConstMetric(c.smsOutbound, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "sms-outbound-longcode":
-			ch <- prometheus.MustNewConstMetric(c.smsOutboundLongCode, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "sms-outbound-shortcode":
-			ch <- prometheus.MustNewConstMetric(c.smsOutboundShortCode, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "mms":
-			ch <- prometheus.MustNewConstMetric(c.mms, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "mms-inbound":
-			ch <- prometheus.MustNewConstMetric(c.mmsInbound, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "mms-inbound-longcode":
-			ch <- prometheus.MustNewConstMetric(c.mmsInboundLongCode, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "mms-inbound-shortcode":
-			ch <- prometheus.MustNewConstMetric(c.mmsInboundShortCode, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "mms-outbound":
-			ch <- prometheus.MustNewConstMetric(c.mmsOutbound, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "mms-outbound-longcode":
-			ch <- prometheus.MustNewConstMetric(c.mmsOutboundLongCode, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "mms-outbound-shortcode":
-			ch <- prometheus.MustNewConstMetric(c.mmsOutboundShortCode, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "recordings":
-			ch <- prometheus.MustNewConstMetric(c.recordings, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "recordingstorage":
-			ch <- prometheus.MustNewConstMetric(c.recordingsStorage, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "transcriptions":
-			ch <- prometheus.MustNewConstMetric(c.transcriptions, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "mediastorage":
-			ch <- prometheus.MustNewConstMetric(c.mediaStorage, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "authy-sms-outbound":
-			ch <- prometheus.MustNewConstMetric(c.authySMSOutbound, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "authy-calls-outbound":
-			ch <- prometheus.MustNewConstMetric(c.authyCallsOutbound, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "authy-authentications":
-			ch <- prometheus.MustNewConstMetric(c.authyAuthentications, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "authy-phone-verifications":
-			ch <- prometheus.MustNewConstMetric(c.authyPhoneVerifications, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "authy-phone-intelligence":
-			ch <- prometheus.MustNewConstMetric(c.authyPhoneIntelligence, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "authy-monthly-fees":
-			ch <- prometheus.MustNewConstMetric(c.authyMonthlyFees, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "monitor-storage":
-			ch <- prometheus.MustNewConstMetric(c.monitorStorage, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "monitor-reads":
-			ch <- prometheus.MustNewConstMetric(c.monitorReads, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "monitor-write":
-			ch <- prometheus.MustNewConstMetric(c.monitorWrites, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "taskrouter-tasks":
-			ch <- prometheus.MustNewConstMetric(c.taskRouterTasks, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "turnmegabytes":
-			ch <- prometheus.MustNewConstMetric(c.turnMegabytes, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "calls-recordings":
-			ch <- prometheus.MustNewConstMetric(c.callRecordings, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "trunking-recordings":
-			ch <- prometheus.MustNewConstMetric(c.trunkingRecordings, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "trunking-termination":
-			ch <- prometheus.MustNewConstMetric(c.trunkingTermination, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		case bodyObject.UsageRecords[k].Category == "trunking-origination":
-			ch <- prometheus.MustNewConstMetric(c.trunkingOrigination, prometheus.CounterValue, bodyObject.UsageRecords[k].Count)
-		}
-	}
-
-}
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//Usage is the outside object from Twilios Usage api
+type Usage struct {
+	FirstPageURI    string         `json:"first_page_uri"`
+	End             int            `json:"end"`
+	PreviousPageURI interface{}    `json:"previous_page_uri"`
+	URI             string         `json:"uri"`
+	PageSize        int            `json:"page_size"`
+	Start           int            `json:"start"`
+	UsageRecords    []UsageRecords `json:"usage_records"`
+	NextPageURI     string         `json:"next_page_uri"`
+	Page            int            `json:"page"`
+}
+
+//UsageRecords are the inside objects from Twilios Usage api
+type UsageRecords struct {
+	Category    string    `json:"category"`
+	Description string    `json:"description"`
+	AccountSid  string    `json:"account_sid"`
+	StartDate   string    `json:"start_date"`
+	EndDate     string    `json:"end_date"`
+	AsOf        time.Time `json:"as_of"`
+	Count       float64   `json:",string"`
+	CountUnit   string    `json:"count_unit"`
+	Usage       float64   `json:",string"`
+	UsageUnit   string    `json:"usage_unit"`
+	Price       float64   `json:",string"`
+	PriceUnit   string    `json:"price_unit"`
+	APIVersion  string    `json:"api_version"`
+	URI         string    `json:"uri"`
+}
+
+//usagePeriods maps the period names accepted by -periods and the periods setting to Twilio's usage record subresources
+var usagePeriods = map[string]string{
+	"today":     "Today",
+	"yesterday": "Yesterday",
+	"thismonth": "ThisMonth",
+	"lastmonth": "LastMonth",
+	"daily":     "Daily",
+	"monthly":   "Monthly",
+	"yearly":    "Yearly",
+	"alltime":   "AllTime",
+}
+
+//usageLabels are the variable labels carried by every usage metric
+var usageLabels = []string{"account_sid", "account_name", "period", "start_date", "end_date"}
+
+//withLabels returns a new slice of labels followed by extra, never sharing the backing array of labels
+func withLabels(labels []string, extra ...string) []string {
+	out := make([]string, 0, len(labels)+len(extra))
+	return append(append(out, labels...), extra...)
+}
+
+//usageDescs holds the count, usage and price descriptions for a single usage category
+type usageDescs struct {
+	count *prometheus.Desc
+	usage *prometheus.Desc
+	price *prometheus.Desc
+}
+
+//newUsageDescs builds the count metric under name along with its name_usage and name_price siblings
+func newUsageDescs(name string, help string) *usageDescs {
+	return &usageDescs{
+		count: prometheus.NewDesc(name, help, usageLabels, nil),
+		usage: prometheus.NewDesc(name+"_usage", help+" usage", withLabels(usageLabels, "usage_unit"), nil),
+		price: prometheus.NewDesc(name+"_price", help+" price", withLabels(usageLabels, "price_unit"), nil),
+	}
+}
+
+//describe sends all three descriptions
+func (d *usageDescs) describe(ch chan<- *prometheus.Desc) {
+	ch <- d.count
+	ch <- d.usage
+	ch <- d.price
+}
+
+//collect emits the count, usage and price of a single record, labelValues are the values for the leading labels of the descriptions
+func (d *usageDescs) collect(ch chan<- prometheus.Metric, record UsageRecords, labelValues ...string) {
+	ch <- prometheus.MustNewConstMetric(d.count, prometheus.CounterValue, record.Count, labelValues...)
+	ch <- prometheus.MustNewConstMetric(d.usage, prometheus.CounterValue, record.Usage, withLabels(labelValues, record.UsageUnit)...)
+	ch <- prometheus.MustNewConstMetric(d.price, prometheus.CounterValue, record.Price, withLabels(labelValues, record.PriceUnit)...)
+}
+
+//UsageCollector exports usage records as the twil_usage_* family labeled by category, and as the legacy per-category metrics from the catalog
+type UsageCollector struct {
+	targets    []accountTarget
+	periods    []string
+	categories map[string]*usageDescs
+	family     *usageDescs
+	legacy     bool
+	labeled    bool
+	include    []*regexp.Regexp
+	exclude    []*regexp.Regexp
+	record     pollRecorder
+	forget     func(endpoint string, polled map[string]bool)
+}
+
+//familyLabels are the leading labels of the twil_usage_* family
+var familyLabels = withLabels([]string{"category"}, usageLabels...)
+
+//newUsageCollector initializes the collectors and assigns fqName and help description for exported metrics.
+//With metrics.legacy set catalog categories keep their own metric names, with metrics.labeled set every record is also exported through the twil_usage_* family.
+//Categories missing from the catalog always go through the family.
+func newUsageCollector(cfg *Config, targets []accountTarget) (*UsageCollector, error) {
+	categories, err := loadCategories(cfg.Categories.File)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &UsageCollector{
+		targets:    targets,
+		periods:    cfg.Periods,
+		categories: make(map[string]*usageDescs, len(categories)),
+		family: &usageDescs{
+			count: prometheus.NewDesc("twil_usage_count", "Count of usage records by category", familyLabels, nil),
+			usage: prometheus.NewDesc("twil_usage_amount", "Usage of usage records by category", withLabels(familyLabels, "usage_unit"), nil),
+			price: prometheus.NewDesc("twil_usage_price", "Price of usage records by category", withLabels(familyLabels, "price_unit"), nil),
+		},
+		legacy:  cfg.Metrics.Legacy,
+		labeled: cfg.Metrics.Labeled,
+		record:  recordPoll,
+		forget:  forgetAccounts,
+	}
+	for _, cat := range categories {
+		c.categories[cat.Category] = newUsageDescs(cat.Metric, cat.Help)
+	}
+	for _, expr := range cfg.Categories.Include {
+		c.include = append(c.include, regexp.MustCompile(expr))
+	}
+	for _, expr := range cfg.Categories.Exclude {
+		c.exclude = append(c.exclude, regexp.MustCompile(expr))
+	}
+	return c, nil
+}
+
+//wanted reports whether category passes the include and exclude filters, with no include filters every category is included
+func (c *UsageCollector) wanted(category string) bool {
+	included := len(c.include) == 0
+	for _, re := range c.include {
+		if re.MatchString(category) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, re := range c.exclude {
+		if re.MatchString(category) {
+			return false
+		}
+	}
+	return true
+}
+
+//Describe initializes channels used to pull Metrics
+func (c *UsageCollector) Describe(ch chan<- *prometheus.Desc) {
+	if c.legacy {
+		for _, d := range c.categories {
+			d.describe(ch)
+		}
+	}
+	c.family.describe(ch)
+}
+
+//fetchUsageRecords returns every usage record of account for period
+func (t *twilioClient) fetchUsageRecords(account string, period string) ([]UsageRecords, error) {
+	var records []UsageRecords
+	err := t.forEachPage(t.listURL("/Accounts/"+account+"/Usage/Records/"+usagePeriods[period]+".json", nil), func(body []byte) error {
+		var bodyObject Usage
+		if err := json.Unmarshal(body, &bodyObject); err != nil {
+			return err
+		}
+		records = append(records, bodyObject.UsageRecords...)
+		return nil
+	})
+	return records, err
+}
+
+//Collect gathers the metrics
+func (c *UsageCollector) Collect(ch chan<- prometheus.Metric) {
+	c.forget("usage", eachAccountRecorded(ch, c.targets, "usage", c.family.count, c.record, c.collectAccount))
+}
+
+//collectAccount emits every period of usage for account and records the health of the poll
+func (c *UsageCollector) collectAccount(ch chan<- prometheus.Metric, client *twilioClient, account AccountRecords) {
+	start := time.Now()
+	var pollErr error
+	for _, period := range c.periods {
+		records, err := client.fetchUsageRecords(account.Sid, period)
+		if err != nil {
+			pollErr = err
+			reportError(ch, c.family.count, fmt.Errorf("fetching %s usage of %s: %w", period, account.Sid, err))
+		}
+		c.collectRecords(ch, account, period, records)
+	}
+	c.record(account.Sid, "usage", start, pollErr)
+}
+
+//collectRecords emits the metrics for every wanted record according to the legacy and labeled modes
+func (c *UsageCollector) collectRecords(ch chan<- prometheus.Metric, account AccountRecords, period string, records []UsageRecords) {
+	for _, record := range records {
+		if !c.wanted(record.Category) {
+			continue
+		}
+		d, known := c.categories[record.Category]
+		if known && c.legacy {
+			d.collect(ch, record, account.Sid, account.FriendlyName, period, record.StartDate, record.EndDate)
+		}
+		if !known || c.labeled {
+			c.family.collect(ch, record, record.Category, account.Sid, account.FriendlyName, period, record.StartDate, record.EndDate)
+		}
+	}
+}
@@ -0,0 +1,667 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+)
+
+//configCheckInterval is how often the -config file is checked for changes
+const configCheckInterval = 10 * time.Second
+
+//Config is the structure of the -config file, without one it is built from flags
+type Config struct {
+	ListenAddress string                  `yaml:"listen_address"`
+	APIURL        string                  `yaml:"api_url"`
+	MonitorURL    string                  `yaml:"monitor_url"`
+	TaskRouterURL string                  `yaml:"taskrouter_url"`
+	Timeout       time.Duration           `yaml:"timeout"`
+	Retry         RetryConfig             `yaml:"retry"`
+	PageSize      int                     `yaml:"page_size"`
+	MaxPages      int                     `yaml:"max_pages"`
+	Periods       []string                `yaml:"periods"`
+	PollIntervals IntervalsConfig         `yaml:"poll_intervals"`
+	Categories    CategoriesConfig        `yaml:"categories"`
+	Metrics       MetricsConfig           `yaml:"metrics"`
+	Collectors    CollectorsConfig        `yaml:"collectors"`
+	Webhooks      WebhooksConfig          `yaml:"webhooks"`
+	Accounts      []AccountConfig         `yaml:"accounts"`
+	Modules       map[string]ModuleConfig `yaml:"modules"`
+}
+
+//RetryConfig controls how throttled and failed Twilio requests are retried, timeout bounds each request including its retries
+type RetryConfig struct {
+	MaxRetries int           `yaml:"max_retries"`
+	BaseDelay  time.Duration `yaml:"base_delay"`
+	MaxDelay   time.Duration `yaml:"max_delay"`
+}
+
+//IntervalsConfig is how often each Twilio endpoint is polled in the background
+type IntervalsConfig struct {
+	Usage        time.Duration `yaml:"usage"`
+	Balance      time.Duration `yaml:"balance"`
+	Messages     time.Duration `yaml:"messages"`
+	Calls        time.Duration `yaml:"calls"`
+	PhoneNumbers time.Duration `yaml:"phone_numbers"`
+	Queues       time.Duration `yaml:"queues"`
+	Live         time.Duration `yaml:"live"`
+	Alerts       time.Duration `yaml:"alerts"`
+	Events       time.Duration `yaml:"events"`
+	TaskRouter   time.Duration `yaml:"taskrouter"`
+}
+
+//CollectorsConfig turns on the collectors beyond usage, which is always collected
+type CollectorsConfig struct {
+	Balance      BalanceConfig      `yaml:"balance"`
+	Messages     MessagesConfig     `yaml:"messages"`
+	Calls        CallsConfig        `yaml:"calls"`
+	PhoneNumbers PhoneNumbersConfig `yaml:"phone_numbers"`
+	Queues       QueuesConfig       `yaml:"queues"`
+	Live         LiveConfig         `yaml:"live"`
+	Alerts       AlertsConfig       `yaml:"alerts"`
+	Events       EventsConfig       `yaml:"events"`
+	TaskRouter   TaskRouterConfig   `yaml:"taskrouter"`
+}
+
+//BalanceConfig turns on the account balance collector
+type BalanceConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+//CallsConfig turns on the call outcome collector
+type CallsConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+//PhoneNumbersConfig turns on the phone number inventory collector, mode info exports a series per number and mode count aggregates numbers set up alike
+type PhoneNumbersConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Mode    string `yaml:"mode"`
+}
+
+//QueuesConfig turns on the call queue collector
+type QueuesConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+//LiveConfig turns on the collector of calls and conferences in progress
+type LiveConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+//AlertsConfig turns on the debugger alerts collector, alerts are counted once they are older than settle
+type AlertsConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Settle  time.Duration `yaml:"settle"`
+}
+
+//EventsConfig turns on the audit events collector, events are counted once they are older than settle and with log set every new event is also written to stdout as JSON
+type EventsConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Settle  time.Duration `yaml:"settle"`
+	Log     bool          `yaml:"log"`
+}
+
+//TaskRouterConfig turns on the TaskRouter statistics collector
+type TaskRouterConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+//MessagesConfig turns on the message status collector, messages are counted once they are older than settle
+type MessagesConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Settle  time.Duration `yaml:"settle"`
+}
+
+//WebhooksConfig turns on the status callback receiver at /callbacks/messages and /callbacks/calls.
+//public_url is the base URL Twilio is configured to call, needed to check signatures when twil is behind a proxy that rewrites the host.
+type WebhooksConfig struct {
+	Enabled      bool               `yaml:"enabled"`
+	PublicURL    string             `yaml:"public_url"`
+	EventStreams EventStreamsConfig `yaml:"event_streams"`
+}
+
+//EventStreamsConfig turns on the Event Streams webhook sink at /callbacks/events, independently of the status callbacks, mapping is a JSON file of event mappings
+type EventStreamsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Mapping string `yaml:"mapping"`
+}
+
+//CategoriesConfig points at an extra category catalog and filters which categories are exported
+type CategoriesConfig struct {
+	File    string   `yaml:"file"`
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+}
+
+//MetricsConfig selects the legacy per-category metrics, the labeled twil_usage_* family or both
+type MetricsConfig struct {
+	Legacy  bool `yaml:"legacy"`
+	Labeled bool `yaml:"labeled"`
+}
+
+//AccountConfig is a Twilio account to collect, optionally along with its subaccounts
+type AccountConfig struct {
+	AccountSid  string            `yaml:"account_sid"`
+	Credentials CredentialsConfig `yaml:"credentials"`
+	Subaccounts SubaccountsConfig `yaml:"subaccounts"`
+}
+
+//CredentialsConfig says where an account's secret comes from, a literal value, an environment variable or a file.
+//When nothing is set the TWILIO_* environment variables are used.
+type CredentialsConfig struct {
+	AuthToken     string `yaml:"auth_token"`
+	AuthTokenEnv  string `yaml:"auth_token_env"`
+	AuthTokenFile string `yaml:"auth_token_file"`
+	APIKey        string `yaml:"api_key"`
+	APISecret     string `yaml:"api_secret"`
+	APISecretEnv  string `yaml:"api_secret_env"`
+	APISecretFile string `yaml:"api_secret_file"`
+}
+
+//ModuleConfig is a set of /probe settings selected with the module parameter, anything left out comes from the top level.
+//Accounts listed under accounts are probed with their own credentials, any other account with those of credentials_from.
+type ModuleConfig struct {
+	Periods         []string          `yaml:"periods"`
+	Categories      *CategoriesConfig `yaml:"categories"`
+	Metrics         *MetricsConfig    `yaml:"metrics"`
+	CredentialsFrom string            `yaml:"credentials_from"`
+}
+
+//SubaccountsConfig turns on subaccount discovery for an account
+type SubaccountsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Filter  string `yaml:"filter"`
+}
+
+//defaultConfig returns the settings used for anything the config file leaves out
+func defaultConfig() *Config {
+	return &Config{
+		ListenAddress: ":2112",
+		APIURL:        twilioAPI,
+		MonitorURL:    monitorAPI,
+		TaskRouterURL: taskRouterAPI,
+		Timeout:       30 * time.Second,
+		Retry:         RetryConfig{MaxRetries: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 10 * time.Second},
+		PageSize:      1000,
+		MaxPages:      20,
+		Periods:       []string{"alltime"},
+		PollIntervals: IntervalsConfig{Usage: 5 * time.Minute, Balance: 5 * time.Minute, Messages: time.Minute, Calls: time.Minute, PhoneNumbers: 15 * time.Minute, Queues: 15 * time.Second, Live: 15 * time.Second, Alerts: time.Minute, Events: time.Minute, TaskRouter: 30 * time.Second},
+		Collectors: CollectorsConfig{
+			Messages:     MessagesConfig{Settle: 5 * time.Minute},
+			Alerts:       AlertsConfig{Settle: 5 * time.Minute},
+			Events:       EventsConfig{Settle: 5 * time.Minute},
+			PhoneNumbers: PhoneNumbersConfig{Mode: "info"},
+		},
+		Metrics: MetricsConfig{Legacy: true},
+	}
+}
+
+//loadConfig reads and validates the config file at path
+func loadConfig(path string) (*Config, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := defaultConfig()
+	if err := yaml.UnmarshalStrict(body, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+//configFromFlags builds the configuration of a single account from the command line flags and TWILIO_* environment variables
+func configFromFlags() *Config {
+	cfg := defaultConfig()
+	cfg.ListenAddress = *Port
+	cfg.Retry.MaxRetries = *MaxRetries
+	cfg.PageSize = *PageSize
+	cfg.MaxPages = *MaxPages
+	cfg.Periods = splitList(*Periods)
+	cfg.PollIntervals.Usage = *UsageInterval
+	cfg.Collectors.Balance.Enabled = *BalanceEnabled
+	cfg.Collectors.Messages.Enabled = *MessagesEnabled
+	cfg.Collectors.Calls.Enabled = *CallsEnabled
+	cfg.Collectors.PhoneNumbers.Enabled = *PhoneNumbersEnabled
+	cfg.Collectors.PhoneNumbers.Mode = *PhoneNumbersMode
+	cfg.Collectors.Queues.Enabled = *QueuesEnabled
+	cfg.Collectors.Live.Enabled = *LiveEnabled
+	cfg.Collectors.Alerts.Enabled = *AlertsEnabled
+	cfg.Collectors.Events.Enabled = *EventsEnabled
+	cfg.Collectors.Events.Log = *EventsLog
+	cfg.Collectors.TaskRouter.Enabled = *TaskRouterEnabled
+	cfg.Webhooks = WebhooksConfig{
+		Enabled:      *WebhooksEnabled,
+		PublicURL:    *WebhooksURL,
+		EventStreams: EventStreamsConfig{Enabled: *EventStreamsEnabled, Mapping: *EventStreamsMapping},
+	}
+	cfg.Categories.File = *Categories
+	cfg.Metrics = MetricsConfig{Legacy: *LegacyMetrics, Labeled: *LabeledMetrics}
+	cfg.Accounts = []AccountConfig{{
+		AccountSid: orEnv(*Account, "TWILIO_ACCOUNT_SID"),
+		Credentials: CredentialsConfig{
+			AuthToken:     orEnv(*Token, "TWILIO_AUTH_TOKEN"),
+			AuthTokenFile: orEnv(*TokenFile, "TWILIO_AUTH_TOKEN_FILE"),
+			APIKey:        orEnv(*APIKey, "TWILIO_API_KEY"),
+			APISecret:     orEnv(*APISecret, "TWILIO_API_SECRET"),
+			APISecretFile: orEnv(*APISecretFile, "TWILIO_API_SECRET_FILE"),
+		},
+		Subaccounts: SubaccountsConfig{Enabled: *Subaccounts, Filter: *SubaccountFilter},
+	}}
+	return cfg
+}
+
+//splitList splits a comma separated list, dropping empty entries
+func splitList(list string) []string {
+	var out []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+//validate checks the configuration, errors name the offending field
+func (cfg *Config) validate() error {
+	if cfg.ListenAddress == "" {
+		return fmt.Errorf("listen_address: must not be empty")
+	}
+	if !strings.HasPrefix(cfg.APIURL, "https://") && !strings.HasPrefix(cfg.APIURL, "http://") {
+		return fmt.Errorf("api_url: %q must start with https://", cfg.APIURL)
+	}
+	if !strings.HasPrefix(cfg.MonitorURL, "https://") && !strings.HasPrefix(cfg.MonitorURL, "http://") {
+		return fmt.Errorf("monitor_url: %q must start with https://", cfg.MonitorURL)
+	}
+	if !strings.HasPrefix(cfg.TaskRouterURL, "https://") && !strings.HasPrefix(cfg.TaskRouterURL, "http://") {
+		return fmt.Errorf("taskrouter_url: %q must start with https://", cfg.TaskRouterURL)
+	}
+	if cfg.Timeout <= 0 {
+		return fmt.Errorf("timeout: must be positive, got %s", cfg.Timeout)
+	}
+	if cfg.Retry.MaxRetries < 0 {
+		return fmt.Errorf("retry.max_retries: must not be negative, got %d", cfg.Retry.MaxRetries)
+	}
+	if cfg.Retry.BaseDelay <= 0 {
+		return fmt.Errorf("retry.base_delay: must be positive, got %s", cfg.Retry.BaseDelay)
+	}
+	if cfg.Retry.MaxDelay < cfg.Retry.BaseDelay {
+		return fmt.Errorf("retry.max_delay: must be at least base_delay %s, got %s", cfg.Retry.BaseDelay, cfg.Retry.MaxDelay)
+	}
+	if cfg.PageSize < 1 || cfg.PageSize > 1000 {
+		return fmt.Errorf("page_size: must be between 1 and 1000, got %d", cfg.PageSize)
+	}
+	if cfg.MaxPages < 0 {
+		return fmt.Errorf("max_pages: must be 0 for no limit or positive, got %d", cfg.MaxPages)
+	}
+
+	if cfg.PollIntervals.Usage < time.Second {
+		return fmt.Errorf("poll_intervals.usage: must be at least 1s, got %s", cfg.PollIntervals.Usage)
+	}
+	if cfg.PollIntervals.Balance < time.Second {
+		return fmt.Errorf("poll_intervals.balance: must be at least 1s, got %s", cfg.PollIntervals.Balance)
+	}
+	if cfg.PollIntervals.Messages < time.Second {
+		return fmt.Errorf("poll_intervals.messages: must be at least 1s, got %s", cfg.PollIntervals.Messages)
+	}
+	if cfg.PollIntervals.Calls < time.Second {
+		return fmt.Errorf("poll_intervals.calls: must be at least 1s, got %s", cfg.PollIntervals.Calls)
+	}
+	if cfg.PollIntervals.PhoneNumbers < time.Second {
+		return fmt.Errorf("poll_intervals.phone_numbers: must be at least 1s, got %s", cfg.PollIntervals.PhoneNumbers)
+	}
+	if cfg.PollIntervals.Queues < time.Second {
+		return fmt.Errorf("poll_intervals.queues: must be at least 1s, got %s", cfg.PollIntervals.Queues)
+	}
+	if cfg.PollIntervals.Live < time.Second {
+		return fmt.Errorf("poll_intervals.live: must be at least 1s, got %s", cfg.PollIntervals.Live)
+	}
+	if cfg.PollIntervals.Alerts < time.Second {
+		return fmt.Errorf("poll_intervals.alerts: must be at least 1s, got %s", cfg.PollIntervals.Alerts)
+	}
+	if cfg.PollIntervals.Events < time.Second {
+		return fmt.Errorf("poll_intervals.events: must be at least 1s, got %s", cfg.PollIntervals.Events)
+	}
+	if cfg.PollIntervals.TaskRouter < time.Second {
+		return fmt.Errorf("poll_intervals.taskrouter: must be at least 1s, got %s", cfg.PollIntervals.TaskRouter)
+	}
+	if cfg.Collectors.Messages.Settle < 0 {
+		return fmt.Errorf("collectors.messages.settle: must not be negative, got %s", cfg.Collectors.Messages.Settle)
+	}
+	if cfg.Collectors.Alerts.Settle < 0 {
+		return fmt.Errorf("collectors.alerts.settle: must not be negative, got %s", cfg.Collectors.Alerts.Settle)
+	}
+	if cfg.Collectors.Events.Settle < 0 {
+		return fmt.Errorf("collectors.events.settle: must not be negative, got %s", cfg.Collectors.Events.Settle)
+	}
+
+	if !phoneNumberModes[cfg.Collectors.PhoneNumbers.Mode] {
+		return fmt.Errorf("collectors.phone_numbers.mode: must be info or count, got %q", cfg.Collectors.PhoneNumbers.Mode)
+	}
+
+	if cfg.Webhooks.PublicURL != "" && !strings.HasPrefix(cfg.Webhooks.PublicURL, "https://") && !strings.HasPrefix(cfg.Webhooks.PublicURL, "http://") {
+		return fmt.Errorf("webhooks.public_url: %q must start with https://", cfg.Webhooks.PublicURL)
+	}
+
+	if len(cfg.Periods) == 0 {
+		return fmt.Errorf("periods: at least one period is required")
+	}
+	for i, p := range cfg.Periods {
+		cfg.Periods[i] = strings.ToLower(p)
+		if _, ok := usagePeriods[cfg.Periods[i]]; !ok {
+			return fmt.Errorf("periods[%d]: unknown usage period %q", i, p)
+		}
+	}
+
+	for i, expr := range cfg.Categories.Include {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("categories.include[%d]: %v", i, err)
+		}
+	}
+	for i, expr := range cfg.Categories.Exclude {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("categories.exclude[%d]: %v", i, err)
+		}
+	}
+
+	if !cfg.Metrics.Legacy && !cfg.Metrics.Labeled {
+		return fmt.Errorf("metrics: at least one of legacy and labeled must be enabled")
+	}
+
+	if len(cfg.Accounts) == 0 {
+		return fmt.Errorf("accounts: at least one account is required")
+	}
+	seen := make(map[string]int, len(cfg.Accounts))
+	for i, account := range cfg.Accounts {
+		if !accountSidRE.MatchString(account.AccountSid) {
+			return fmt.Errorf("accounts[%d].account_sid: %q is malformed, expected AC followed by 32 hex characters", i, account.AccountSid)
+		}
+		if j, ok := seen[account.AccountSid]; ok {
+			return fmt.Errorf("accounts[%d].account_sid: %s is already configured by accounts[%d]", i, account.AccountSid, j)
+		}
+		seen[account.AccountSid] = i
+		if err := account.Credentials.validate(); err != nil {
+			return fmt.Errorf("accounts[%d].credentials.%v", i, err)
+		}
+		if _, err := regexp.Compile(account.Subaccounts.Filter); err != nil {
+			return fmt.Errorf("accounts[%d].subaccounts.filter: %v", i, err)
+		}
+	}
+
+	for name, module := range cfg.Modules {
+		if err := module.validate(cfg); err != nil {
+			return fmt.Errorf("modules.%s.%v", name, err)
+		}
+	}
+	return nil
+}
+
+//validate checks a module against the configuration it belongs to, errors start with the offending field
+func (m ModuleConfig) validate(cfg *Config) error {
+	for i, p := range m.Periods {
+		m.Periods[i] = strings.ToLower(p)
+		if _, ok := usagePeriods[m.Periods[i]]; !ok {
+			return fmt.Errorf("periods[%d]: unknown usage period %q", i, p)
+		}
+	}
+	if m.Categories != nil {
+		for i, expr := range m.Categories.Include {
+			if _, err := regexp.Compile(expr); err != nil {
+				return fmt.Errorf("categories.include[%d]: %v", i, err)
+			}
+		}
+		for i, expr := range m.Categories.Exclude {
+			if _, err := regexp.Compile(expr); err != nil {
+				return fmt.Errorf("categories.exclude[%d]: %v", i, err)
+			}
+		}
+	}
+	if m.Metrics != nil && !m.Metrics.Legacy && !m.Metrics.Labeled {
+		return fmt.Errorf("metrics: at least one of legacy and labeled must be enabled")
+	}
+	if m.CredentialsFrom != "" {
+		if _, ok := cfg.account(m.CredentialsFrom); !ok {
+			return fmt.Errorf("credentials_from: %s is not one of the configured accounts", m.CredentialsFrom)
+		}
+	}
+	return nil
+}
+
+//account returns the configured account with sid
+func (cfg *Config) account(sid string) (AccountConfig, bool) {
+	for _, account := range cfg.Accounts {
+		if account.AccountSid == sid {
+			return account, true
+		}
+	}
+	return AccountConfig{}, false
+}
+
+//validate checks that each secret comes from at most one place, errors start with the offending field
+func (c CredentialsConfig) validate() error {
+	if n := countSet(c.AuthToken, c.AuthTokenEnv, c.AuthTokenFile); n > 1 {
+		return fmt.Errorf("auth_token: set only one of auth_token, auth_token_env and auth_token_file")
+	}
+	if n := countSet(c.APISecret, c.APISecretEnv, c.APISecretFile); n > 1 {
+		return fmt.Errorf("api_secret: set only one of api_secret, api_secret_env and api_secret_file")
+	}
+	if c.APIKey != "" && !apiKeySidRE.MatchString(c.APIKey) {
+		return fmt.Errorf("api_key: %q is malformed, expected SK followed by 32 hex characters", c.APIKey)
+	}
+	return nil
+}
+
+//countSet counts the non-empty values
+func countSet(values ...string) int {
+	n := 0
+	for _, v := range values {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
+
+//source resolves the credentials of account, falling back to the TWILIO_* environment variables when none are configured
+func (account AccountConfig) source() (*credentialSource, error) {
+	c := account.Credentials
+	if c == (CredentialsConfig{}) {
+		c = CredentialsConfig{
+			AuthToken:     os.Getenv("TWILIO_AUTH_TOKEN"),
+			AuthTokenFile: os.Getenv("TWILIO_AUTH_TOKEN_FILE"),
+			APIKey:        os.Getenv("TWILIO_API_KEY"),
+			APISecret:     os.Getenv("TWILIO_API_SECRET"),
+			APISecretFile: os.Getenv("TWILIO_API_SECRET_FILE"),
+		}
+	}
+	if c.AuthTokenEnv != "" {
+		c.AuthToken = os.Getenv(c.AuthTokenEnv)
+	}
+	if c.APISecretEnv != "" {
+		c.APISecret = os.Getenv(c.APISecretEnv)
+	}
+
+	return newCredentialSource(Credentials{
+		AccountSid: account.AccountSid,
+		AuthToken:  c.AuthToken,
+		APIKey:     c.APIKey,
+		APISecret:  c.APISecret,
+	}, c.AuthTokenFile, c.APISecretFile)
+}
+
+//buildCollector creates the collectors for a validated configuration and starts their background polling.
+//It also returns the credential sources of the collectors by configured account sid, for checking webhook signatures with the same secrets.
+func buildCollector(cfg *Config) (collectorGroup, map[string]*credentialSource, error) {
+	targets := make([]accountTarget, 0, len(cfg.Accounts))
+	sources := make(map[string]*credentialSource, len(cfg.Accounts))
+	for i, account := range cfg.Accounts {
+		credentials, err := account.source()
+		if err != nil {
+			return nil, nil, fmt.Errorf("accounts[%d]: %v", i, err)
+		}
+		sources[account.AccountSid] = credentials
+		current, _ := credentials.Credentials()
+		log.Printf("authenticating to Twilio account %s with %s", current.AccountSid, current.Kind())
+
+		target := newAccountTarget(account.AccountSid, newTwilioClient(credentials, cfg))
+		target.discover = account.Subaccounts.Enabled
+		if account.Subaccounts.Filter != "" {
+			target.filter = regexp.MustCompile(account.Subaccounts.Filter)
+		}
+		targets = append(targets, target)
+	}
+	usage, err := newUsageCollector(cfg, targets)
+	if err != nil {
+		return nil, nil, err
+	}
+	group := collectorGroup{newCachedCollector("usage", usage, cfg.PollIntervals.Usage)}
+	if cfg.Collectors.Balance.Enabled {
+		group = append(group, newCachedCollector("balance", newBalanceCollector(targets), cfg.PollIntervals.Balance))
+	}
+	if cfg.Collectors.Messages.Enabled {
+		group = append(group, newCachedCollector("messages", newMessagesCollector(targets, cfg.Collectors.Messages.Settle), cfg.PollIntervals.Messages))
+	}
+	if cfg.Collectors.Calls.Enabled {
+		group = append(group, newCachedCollector("calls", newCallsCollector(targets), cfg.PollIntervals.Calls))
+	}
+	if cfg.Collectors.PhoneNumbers.Enabled {
+		group = append(group, newCachedCollector("phone_numbers", newPhoneNumbersCollector(targets, cfg.Collectors.PhoneNumbers.Mode), cfg.PollIntervals.PhoneNumbers))
+	}
+	if cfg.Collectors.Queues.Enabled {
+		group = append(group, newCachedCollector("queues", newQueuesCollector(targets), cfg.PollIntervals.Queues))
+	}
+	if cfg.Collectors.Live.Enabled {
+		group = append(group, newCachedCollector("live", newLiveCollector(targets), cfg.PollIntervals.Live))
+	}
+	if cfg.Collectors.Alerts.Enabled {
+		group = append(group, newCachedCollector("alerts", newAlertsCollector(targets, cfg.Collectors.Alerts.Settle), cfg.PollIntervals.Alerts))
+	}
+	if cfg.Collectors.Events.Enabled {
+		group = append(group, newCachedCollector("events", newEventsCollector(targets, cfg.Collectors.Events.Settle, cfg.Collectors.Events.Log), cfg.PollIntervals.Events))
+	}
+	if cfg.Collectors.TaskRouter.Enabled {
+		group = append(group, newCachedCollector("taskrouter", newTaskRouterCollector(targets), cfg.PollIntervals.TaskRouter))
+	}
+	if cfg.Webhooks.EventStreams.Enabled {
+		sink, err := newEventStreamSink(cfg.Webhooks.EventStreams.Mapping)
+		if err == nil {
+			err = sink.checkNames(group)
+		}
+		if err != nil {
+			group.Stop()
+			return nil, nil, fmt.Errorf("webhooks.event_streams.mapping: %v", err)
+		}
+		group = append(group, sink)
+	}
+	return group, sources, nil
+}
+
+//reloadableCollector serves metrics from the collector built from the latest good configuration, and hands that configuration to /probe.
+//It describes nothing, making it an unchecked collector, so a reload can change the exported metrics without re-registering.
+type reloadableCollector struct {
+	mu      sync.RWMutex
+	cfg     *Config
+	current collectorGroup
+	sources map[string]*credentialSource
+}
+
+//set swaps in the configuration, collectors and credential sources used by future scrapes and webhooks, stops the collectors they replace and drops the health series of endpoints no longer polled
+func (r *reloadableCollector) set(cfg *Config, c collectorGroup, sources map[string]*credentialSource) {
+	r.mu.Lock()
+	old := r.current
+	r.cfg = cfg
+	r.current = c
+	r.sources = sources
+	r.mu.Unlock()
+	old.Stop()
+	forgetEndpoints(c.endpoints())
+}
+
+//config returns the running configuration
+func (r *reloadableCollector) config() *Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cfg
+}
+
+//credentials returns the credential sources of the running collectors by configured account sid
+func (r *reloadableCollector) credentials() map[string]*credentialSource {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sources
+}
+
+//Describe sends nothing, see reloadableCollector
+func (r *reloadableCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+//Collect gathers the metrics of the current collector
+func (r *reloadableCollector) Collect(ch chan<- prometheus.Metric) {
+	r.mu.RLock()
+	c := r.current
+	r.mu.RUnlock()
+	c.Collect(ch)
+}
+
+//watchConfig reloads the config file at path on SIGHUP or when it changes on disk, a configuration that fails to load or build is logged and the running one kept
+func watchConfig(path string, running *Config, target *reloadableCollector) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(configCheckInterval)
+	defer ticker.Stop()
+
+	lastMod := modTime(path)
+	for {
+		select {
+		case <-hup:
+			log.Printf("SIGHUP received, reloading %s", path)
+		case <-ticker.C:
+			mod := modTime(path)
+			if mod.Equal(lastMod) {
+				continue
+			}
+			log.Printf("%s changed, reloading", path)
+		}
+		lastMod = modTime(path)
+
+		cfg, err := loadConfig(path)
+		if err != nil {
+			log.Printf("reload failed, keeping the running configuration: %v", err)
+			continue
+		}
+		collector, sources, err := buildCollector(cfg)
+		if err != nil {
+			log.Printf("reload failed, keeping the running configuration: %v", err)
+			continue
+		}
+		if cfg.ListenAddress != running.ListenAddress {
+			log.Printf("listen_address changed from %s to %s, restart twil for it to take effect", running.ListenAddress, cfg.ListenAddress)
+			cfg.ListenAddress = running.ListenAddress
+		}
+		target.set(cfg, collector, sources)
+		running = cfg
+		log.Printf("reloaded %s", path)
+	}
+}
+
+//modTime returns the modification time of path, or the zero time when it can't be read
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
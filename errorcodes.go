@@ -0,0 +1,50 @@
+package main
+
+//errorCodes is the catalog of Twilio error codes twil can describe out of the box, see https://www.twilio.com/docs/api/errors
+var errorCodes = map[string]string{
+	"11100": "Invalid URL format",
+	"11200": "HTTP retrieval failure",
+	"11205": "HTTP connection failure",
+	"11206": "HTTP protocol violation",
+	"11210": "HTTP bad host name",
+	"11215": "HTTP too many redirects",
+	"11220": "SSL/TLS handshake error",
+	"11237": "Certificate invalid, could not find path to certificate",
+	"11750": "TwiML response body too large",
+	"12100": "Document parse failure",
+	"12200": "Schema validation warning",
+	"12300": "Invalid Content-Type",
+	"13214": "Dial: Invalid callerId value",
+	"13224": "Dial: Invalid phone number",
+	"13225": "Dial: Forbidden phone number",
+	"13227": "Dial: No international authorization",
+	"20003": "Authentication error",
+	"20404": "Resource not found",
+	"20429": "Too many requests",
+	"20500": "Internal server error",
+	"21211": "Invalid 'To' phone number",
+	"21212": "Invalid 'From' phone number",
+	"21408": "Permission to send an SMS has not been enabled for the region",
+	"21602": "Message body is required",
+	"21610": "Attempt to send to unsubscribed recipient",
+	"21611": "'From' number has exceeded the maximum number of queued messages",
+	"21612": "'To' phone number is not currently reachable via SMS",
+	"21614": "'To' number is not a valid mobile number",
+	"21617": "Concatenated message body exceeds the 1600 character limit",
+	"30001": "Queue overflow",
+	"30002": "Account suspended",
+	"30003": "Unreachable destination handset",
+	"30004": "Message blocked",
+	"30005": "Unknown destination handset",
+	"30006": "Landline or unreachable carrier",
+	"30007": "Message filtered by carrier",
+	"30008": "Unknown error",
+	"30009": "Missing segment",
+	"30010": "Message price exceeds max price",
+	"30034": "Message from an unregistered number",
+}
+
+//errorDescription returns the catalog description of a Twilio error code, empty when the code isn't in the catalog
+func errorDescription(code string) string {
+	return errorCodes[code]
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//Events is the outside object from Twilios Monitor Events api, the events are kept raw so they can be logged as Twilio sent them
+type Events struct {
+	Events []json.RawMessage `json:"events"`
+}
+
+//EventRecords are the inside objects from Twilios Monitor Events api
+type EventRecords struct {
+	Sid          string `json:"sid"`
+	AccountSid   string `json:"account_sid"`
+	EventDate    string `json:"event_date"`
+	EventType    string `json:"event_type"`
+	ResourceType string `json:"resource_type"`
+	ResourceSid  string `json:"resource_sid"`
+	ActorType    string `json:"actor_type"`
+	ActorSid     string `json:"actor_sid"`
+}
+
+//listedEvent is an event along with its raw JSON
+type listedEvent struct {
+	record EventRecords
+	raw    json.RawMessage
+}
+
+//eventLog writes one audit event per line to stdout, as bare JSON so log shippers can parse it
+var eventLog = log.New(os.Stdout, "", 0)
+
+//EventsCollector counts the audit events of Twilio's Monitor by type as they happen, reading the Monitor Events api incrementally from a high-water mark on EventDate.
+//Like alerts, events are only counted once they are older than settle.
+type EventsCollector struct {
+	targets []accountTarget
+	settle  time.Duration
+	logged  bool
+	events  *prometheus.CounterVec
+
+	mu    sync.Mutex
+	marks map[string]*highWaterMark
+}
+
+//newEventsCollector creates a collector counting events of targets from now on, with logged set every new event is also written to stdout
+func newEventsCollector(targets []accountTarget, settle time.Duration, logged bool) *EventsCollector {
+	return &EventsCollector{
+		targets: targets,
+		settle:  settle,
+		logged:  logged,
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "twil_events_total",
+			Help: "Monitor audit events since twil started, by event type, resource type and actor type",
+		}, []string{"account_sid", "event_type", "resource_type", "actor_type"}),
+		marks: map[string]*highWaterMark{},
+	}
+}
+
+//eventsErrorDesc identifies the invalid metrics of failed event polls
+var eventsErrorDesc = prometheus.NewDesc("twil_events_total", "Monitor audit events since twil started", nil, nil)
+
+//Describe initializes channels used to pull Metrics
+func (c *EventsCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.events.Describe(ch)
+}
+
+//Collect reads the events since the last poll and gathers the counters
+func (c *EventsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	eachAccount(ch, c.targets, "events", eventsErrorDesc, c.collectAccount)
+	c.events.Collect(ch)
+}
+
+//collectAccount counts the settled events of account since its high-water mark and records the health of the poll
+func (c *EventsCollector) collectAccount(ch chan<- prometheus.Metric, client *twilioClient, account AccountRecords) {
+	if !ownAccount("events", client, account.Sid) {
+		return
+	}
+	start := time.Now()
+	cutoff := start.Add(-c.settle)
+	mark, ok := c.marks[account.Sid]
+	if !ok {
+		mark = newHighWaterMark(cutoff)
+		c.marks[account.Sid] = mark
+	}
+
+	query := url.Values{"StartDate": {mark.at.UTC().Format(time.RFC3339)}, "EndDate": {cutoff.UTC().Format(time.RFC3339)}}
+	fresh, err := client.readSince(client.v1ListURL(client.monitorURL, "/Events", query), mark, cutoff, decodeEvents)
+	for _, record := range fresh {
+		mark.advance(record.sid, record.created)
+		event := record.value.(listedEvent)
+		c.events.WithLabelValues(account.Sid, event.record.EventType, event.record.ResourceType, event.record.ActorType).Inc()
+		if c.logged {
+			logEvent(event.raw)
+		}
+	}
+	if err != nil {
+		reportError(ch, eventsErrorDesc, fmt.Errorf("reading events of %s: %w", account.Sid, err))
+	}
+	recordPoll(account.Sid, "events", start, err)
+}
+
+//logEvent writes raw to the event log on a single line
+func logEvent(raw json.RawMessage) {
+	var line bytes.Buffer
+	if err := json.Compact(&line, raw); err != nil {
+		log.Println("logging event:", err)
+		return
+	}
+	eventLog.Println(line.String())
+}
+
+//decodeEvents decodes a page of the Events list for readSince
+func decodeEvents(body []byte) ([]listedRecord, error) {
+	var bodyObject Events
+	if err := json.Unmarshal(body, &bodyObject); err != nil {
+		return nil, err
+	}
+	records := make([]listedRecord, 0, len(bodyObject.Events))
+	for _, raw := range bodyObject.Events {
+		var event EventRecords
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return nil, err
+		}
+		created, err := time.Parse(time.RFC3339, event.EventDate)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, listedRecord{sid: event.Sid, created: created, value: listedEvent{record: event, raw: raw}})
+	}
+	return records, nil
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//CloudEvent is an event delivered by Twilio Event Streams to a webhook sink
+type CloudEvent struct {
+	ID     string                 `json:"id"`
+	Type   string                 `json:"type"`
+	Source string                 `json:"source"`
+	Time   string                 `json:"time"`
+	Data   map[string]interface{} `json:"data"`
+}
+
+//EventMapping turns the events whose type starts with Type into the counter Metric, labeled by Labels which map label names to attributes of the event data.
+//Nested attributes are reached with dots, like payload.status.
+type EventMapping struct {
+	Type   string            `json:"type"`
+	Metric string            `json:"metric"`
+	Help   string            `json:"help"`
+	Labels map[string]string `json:"labels"`
+}
+
+//labelNameRE matches valid Prometheus label names
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+//eventStreamLabels are the labels of every event stream counter, mappings can't reuse them
+var eventStreamLabels = []string{"account_sid", "type"}
+
+//loadEventMappings reads the JSON array of event mappings in path, no path means no mappings
+func loadEventMappings(path string) ([]EventMapping, error) {
+	if path == "" {
+		return nil, nil
+	}
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var mappings []EventMapping
+	if err := json.Unmarshal(body, &mappings); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	metrics := map[string]bool{"twil_event_stream_events_total": true}
+	for i, m := range mappings {
+		if m.Type == "" || m.Metric == "" {
+			return nil, fmt.Errorf("%s: entry %d needs both type and metric", path, i)
+		}
+		if !metricNameRE.MatchString(m.Metric) {
+			return nil, fmt.Errorf("%s: entry %d: invalid metric name %q", path, i, m.Metric)
+		}
+		if metrics[m.Metric] {
+			return nil, fmt.Errorf("%s: entry %d: metric %q is already used", path, i, m.Metric)
+		}
+		metrics[m.Metric] = true
+		for name := range m.Labels {
+			if !labelNameRE.MatchString(name) || name == "account_sid" || name == "type" {
+				return nil, fmt.Errorf("%s: entry %d: invalid label name %q", path, i, name)
+			}
+		}
+	}
+	return mappings, nil
+}
+
+//eventCounter is a mapping along with its counter and its label names in order
+type eventCounter struct {
+	EventMapping
+	labels  []string
+	counter *prometheus.CounterVec
+}
+
+//EventStreamSink counts the CloudEvents Twilio Event Streams delivers to /callbacks/events, every event by type and those matching a mapping also by the attributes it selects.
+//It is rebuilt along with the collectors on a configuration reload, which resets its counters.
+type EventStreamSink struct {
+	events   *prometheus.CounterVec
+	counters []eventCounter
+	seen     *callbackTracker
+}
+
+//newEventStreamSink creates a sink counting events with the mappings in path
+func newEventStreamSink(path string) (*EventStreamSink, error) {
+	mappings, err := loadEventMappings(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &EventStreamSink{
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "twil_event_stream_events_total",
+			Help: "Event Streams events received, by type",
+		}, eventStreamLabels),
+		seen: newCallbackTracker(),
+	}
+	for _, m := range mappings {
+		c := eventCounter{EventMapping: m}
+		for name := range m.Labels {
+			c.labels = append(c.labels, name)
+		}
+		sort.Strings(c.labels)
+		help := m.Help
+		if help == "" {
+			help = "Event Streams events of type " + m.Type + "*"
+		}
+		c.counter = prometheus.NewCounterVec(prometheus.CounterOpts{Name: m.Metric, Help: help}, withLabels(eventStreamLabels, c.labels...))
+		s.counters = append(s.counters, c)
+	}
+	return s, nil
+}
+
+//Describe initializes channels used to pull Metrics
+func (s *EventStreamSink) Describe(ch chan<- *prometheus.Desc) {
+	s.events.Describe(ch)
+	for _, c := range s.counters {
+		c.counter.Describe(ch)
+	}
+}
+
+//Collect gathers the metrics
+func (s *EventStreamSink) Collect(ch chan<- prometheus.Metric) {
+	s.events.Collect(ch)
+	for _, c := range s.counters {
+		c.counter.Collect(ch)
+	}
+}
+
+//checkNames returns an error naming the first mapping whose metric is already exported by the collectors of group or the metrics registered once at startup
+func (s *EventStreamSink) checkNames(group collectorGroup) error {
+	registry := prometheus.NewRegistry()
+	for _, c := range append(append([]prometheus.Collector{group, s.events}, healthCollectors...), webhookCollectors...) {
+		if err := registry.Register(c); err != nil {
+			return err
+		}
+	}
+	for _, c := range s.counters {
+		if err := registry.Register(c.counter); err != nil {
+			return fmt.Errorf("metric %q is already exported by twil", c.Metric)
+		}
+	}
+	return nil
+}
+
+//record counts event of account once, in the first mapping whose type it matches.
+//Events are told apart by their id, an event without one is counted every time it is delivered.
+func (s *EventStreamSink) record(account string, event CloudEvent, now time.Time) {
+	if event.ID != "" {
+		if _, fresh := s.seen.record(event.ID, "received", now); !fresh {
+			return
+		}
+	}
+	s.events.WithLabelValues(account, event.Type).Inc()
+	for _, c := range s.counters {
+		if !strings.HasPrefix(event.Type, c.Type) {
+			continue
+		}
+		values := []string{account, event.Type}
+		for _, name := range c.labels {
+			values = append(values, attribute(event.Data, c.Labels[name]))
+		}
+		c.counter.WithLabelValues(values...).Inc()
+		return
+	}
+}
+
+//attribute returns the event data attribute at the dotted path as a label value, empty when it is missing or not a plain value
+func attribute(data map[string]interface{}, path string) string {
+	var value interface{} = data
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = object[key]
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+//eventSink returns the event stream sink of the current collectors, nil when it is off
+func (r *reloadableCollector) eventSink() *EventStreamSink {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.current {
+		if sink, ok := c.(*EventStreamSink); ok {
+			return sink
+		}
+	}
+	return nil
+}
+
+//eventStreamHandler serves the Event Streams webhook sink, accepting batches of CloudEvents signed with the Auth Token of a configured account.
+//Twilio signs the URL, which carries the SHA-256 of the body in its bodySHA256 parameter.
+func eventStreamHandler(running *reloadableCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := running.config()
+		sink := running.eventSink()
+		if sink == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			webhookRejectedCounter.WithLabelValues(rejectMethod).Inc()
+			http.Error(w, "events must be POSTed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, eventStreamBodyLimit))
+		if err != nil {
+			webhookRejectedCounter.WithLabelValues(rejectBody).Inc()
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		account, reason := signedBy(running.credentials(), callbackURL(cfg, r), r.URL.Query(), body, r.Header.Get("X-Twilio-Signature"))
+		if reason != "" {
+			webhookRejectedCounter.WithLabelValues(reason).Inc()
+			log.Printf("rejected Event Streams delivery: %s", reason)
+			http.Error(w, "invalid signature", http.StatusForbidden)
+			return
+		}
+
+		events, err := decodeCloudEvents(body)
+		if err != nil {
+			webhookRejectedCounter.WithLabelValues(rejectBody).Inc()
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		now := time.Now()
+		for _, event := range events {
+			sink.record(account, event, now)
+		}
+	})
+}
+
+//eventStreamBodyLimit bounds the size of an Event Streams batch
+const eventStreamBodyLimit = 4 << 20
+
+//signedBy returns the configured account whose Auth Token signed the request to callbackURL with body, or why the request is rejected
+func signedBy(sources map[string]*credentialSource, callbackURL string, query url.Values, body []byte, signature string) (string, string) {
+	sum := sha256.Sum256(body)
+	if query.Get("bodySHA256") != hex.EncodeToString(sum[:]) {
+		return "", rejectSignature
+	}
+	reason := rejectAccount
+	for sid := range sources {
+		token, ok := authToken(sources, sid)
+		if !ok {
+			continue
+		}
+		if validSignature(token, callbackURL, nil, signature) {
+			return sid, ""
+		}
+		reason = rejectSignature
+	}
+	return "", reason
+}
+
+//decodeCloudEvents decodes a batch of CloudEvents, or a single one
+func decodeCloudEvents(body []byte) ([]CloudEvent, error) {
+	var events []CloudEvent
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+		var event CloudEvent
+		err := json.Unmarshal(trimmed, &event)
+		return []CloudEvent{event}, err
+	}
+	err := json.Unmarshal(body, &events)
+	return events, err
+}
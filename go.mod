@@ -2,4 +2,7 @@ module github.com/AndrewFelt/twil
 
 go 1.14
 
-require github.com/prometheus/client_golang v1.7.1
+require (
+	github.com/prometheus/client_golang v1.7.1
+	gopkg.in/yaml.v2 v2.4.0
+)
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//Reasons a Twilio request can fail, the values of the reason label of twil_scrape_errors_total
+const (
+	reasonNetwork    = "network"
+	reasonHTTPStatus = "http_status"
+	reasonDecode     = "decode"
+	reasonAuth       = "auth"
+	reasonPageLimit  = "page_limit"
+)
+
+//TwilioError is the body Twilio returns with a failed request
+type TwilioError struct {
+	Code     int    `json:"code"`
+	Message  string `json:"message"`
+	MoreInfo string `json:"more_info"`
+	Status   int    `json:"status"`
+}
+
+//apiError is a failed Twilio request along with why it failed, and Twilio's explanation when it gave one
+type apiError struct {
+	reason string
+	err    error
+	twilio *TwilioError
+}
+
+//Error describes the failure, including Twilio's error code, message and more_info link when there is one
+func (e *apiError) Error() string {
+	if e.twilio == nil || e.twilio.Code == 0 {
+		return e.err.Error()
+	}
+	msg := fmt.Sprintf("%v: Twilio error %d: %s", e.err, e.twilio.Code, e.twilio.Message)
+	if e.twilio.MoreInfo != "" {
+		msg += " (" + e.twilio.MoreInfo + ")"
+	}
+	return msg
+}
+
+//Unwrap returns the underlying error
+func (e *apiError) Unwrap() error {
+	return e.err
+}
+
+//newAPIError wraps err with reason, or returns nil when err is nil
+func newAPIError(reason string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &apiError{reason: reason, err: err}
+}
+
+//statusError builds the error for a response with a non-2xx status, decoding Twilio's error body when there is one
+func statusError(method string, reqURL string, status int, statusText string, body []byte) error {
+	reason := reasonHTTPStatus
+	if status == http.StatusUnauthorized || status == http.StatusForbidden {
+		reason = reasonAuth
+	}
+	e := &apiError{reason: reason, err: fmt.Errorf("%s %s: %s", method, reqURL, statusText)}
+	var twilio TwilioError
+	if json.Unmarshal(body, &twilio) == nil && twilio.Code != 0 {
+		e.twilio = &twilio
+	}
+	return e
+}
+
+//decodeError wraps a failure to decode the body of reqURL
+func decodeError(reqURL string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return newAPIError(reasonDecode, fmt.Errorf("decoding %s: %w", reqURL, err))
+}
+
+//errorReason returns the reason err failed, errors that didn't come from a request count as network failures
+func errorReason(err error) string {
+	var e *apiError
+	if errors.As(err, &e) {
+		return e.reason
+	}
+	return reasonNetwork
+}
+
+//Health metrics about twil itself, registered once so they survive configuration reloads
+var (
+	upGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "twil_up",
+		Help: "Whether the last poll of an endpoint for an account succeeded",
+	}, []string{"account_sid", "endpoint"})
+	scrapeDurationGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "twil_scrape_duration_seconds",
+		Help: "How long the last poll of an endpoint for an account took",
+	}, []string{"account_sid", "endpoint"})
+	lastSuccessGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "twil_last_success_timestamp_seconds",
+		Help: "Unix time of the last successful poll of an endpoint for an account",
+	}, []string{"account_sid", "endpoint"})
+	scrapeErrorsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "twil_scrape_errors_total",
+		Help: "Failed polls of an endpoint for an account by reason: network, http_status, decode, auth or page_limit",
+	}, []string{"account_sid", "endpoint", "reason"})
+	skippedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "twil_skipped_seconds_total",
+		Help: "Span of creation times whose records were skipped because reading them ran out of max_pages",
+	}, []string{"account_sid", "endpoint"})
+)
+
+//healthCollectors are the health metrics to register
+var healthCollectors = []prometheus.Collector{upGauge, scrapeDurationGauge, lastSuccessGauge, scrapeErrorsCounter, skippedCounter, retriesCounter, throttledCounter}
+
+//polledAccounts are the accounts each endpoint polled last, guarded by polledMu, so the health series of accounts no longer collected can be dropped
+var (
+	polledMu       sync.Mutex
+	polledAccounts = map[string]map[string]bool{}
+)
+
+//forgetAccounts drops the health series of endpoint for the accounts it polled before but not in polled, such as accounts removed by a reload or closed subaccounts
+func forgetAccounts(endpoint string, polled map[string]bool) {
+	polledMu.Lock()
+	defer polledMu.Unlock()
+	for account := range polledAccounts[endpoint] {
+		if !polled[account] {
+			forgetHealth(account, endpoint)
+		}
+	}
+	polledAccounts[endpoint] = polled
+}
+
+//forgetEndpoints drops the health series of every endpoint not in kept, after a reload turned its collector off
+func forgetEndpoints(kept map[string]bool) {
+	polledMu.Lock()
+	defer polledMu.Unlock()
+	for endpoint, accounts := range polledAccounts {
+		if kept[endpoint] {
+			continue
+		}
+		for account := range accounts {
+			forgetHealth(account, endpoint)
+		}
+		delete(polledAccounts, endpoint)
+	}
+}
+
+//forgetHealth deletes every health series of account and endpoint
+func forgetHealth(account string, endpoint string) {
+	upGauge.DeleteLabelValues(account, endpoint)
+	scrapeDurationGauge.DeleteLabelValues(account, endpoint)
+	lastSuccessGauge.DeleteLabelValues(account, endpoint)
+	skippedCounter.DeleteLabelValues(account, endpoint)
+	for _, reason := range []string{reasonNetwork, reasonHTTPStatus, reasonDecode, reasonAuth, reasonPageLimit} {
+		scrapeErrorsCounter.DeleteLabelValues(account, endpoint, reason)
+	}
+}
+
+//pollRecorder records the outcome of a poll of endpoint for account, recordPoll is the one updating the health metrics
+type pollRecorder func(account string, endpoint string, start time.Time, err error)
+
+//recordPoll updates the health metrics of account and endpoint for a poll that started at start and ended with err
+func recordPoll(account string, endpoint string, start time.Time, err error) {
+	scrapeDurationGauge.WithLabelValues(account, endpoint).Set(time.Since(start).Seconds())
+	if err != nil {
+		upGauge.WithLabelValues(account, endpoint).Set(0)
+		scrapeErrorsCounter.WithLabelValues(account, endpoint, errorReason(err)).Inc()
+		var skipped *skipError
+		if errors.As(err, &skipped) {
+			skippedCounter.WithLabelValues(account, endpoint).Add(skipped.to.Sub(skipped.from).Seconds())
+		}
+		return
+	}
+	upGauge.WithLabelValues(account, endpoint).Set(1)
+	lastSuccessGauge.WithLabelValues(account, endpoint).SetToCurrentTime()
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+//twilioTime is the layout of the dates in Twilio's 2010-04-01 API, e.g. Mon, 16 Aug 2010 03:45:01 +0000
+const twilioTime = time.RFC1123Z
+
+//highWaterMark remembers how far an incrementally read list has been counted, the newest creation time counted and the sids counted at exactly that time
+type highWaterMark struct {
+	at   time.Time
+	sids map[string]bool
+}
+
+//newHighWaterMark starts counting records created after at
+func newHighWaterMark(at time.Time) *highWaterMark {
+	return &highWaterMark{at: at, sids: map[string]bool{}}
+}
+
+//isNew reports whether the record sid created at created has not been counted yet
+func (m *highWaterMark) isNew(sid string, created time.Time) bool {
+	return created.After(m.at) || (created.Equal(m.at) && !m.sids[sid])
+}
+
+//advance marks the record sid created at created as counted
+func (m *highWaterMark) advance(sid string, created time.Time) {
+	switch {
+	case created.After(m.at):
+		m.at = created
+		m.sids = map[string]bool{sid: true}
+	case created.Equal(m.at):
+		m.sids[sid] = true
+	}
+}
+
+//skipTo moves the mark forward to at without counting the records before it
+func (m *highWaterMark) skipTo(at time.Time) {
+	m.at = at
+	m.sids = map[string]bool{}
+}
+
+//skipError is the error of a read cut short by max_pages, which moved the mark past the records created from from to to it couldn't reach
+type skipError struct {
+	from time.Time
+	to   time.Time
+	err  error
+}
+
+//Error describes the skipped records and why they were skipped
+func (e *skipError) Error() string {
+	return fmt.Sprintf("skipped the records created from %s to %s: %v", e.from.UTC().Format(time.RFC3339), e.to.UTC().Format(time.RFC3339), e.err)
+}
+
+//Unwrap returns the underlying error
+func (e *skipError) Unwrap() error {
+	return e.err
+}
+
+//skipAhead moves mark past the records a read cut short by max_pages couldn't reach, so the next read makes progress instead of hitting the limit again.
+//records are the records read, oldest first, the mark moves to the oldest of them, or to cutoff when it is older still. err is returned wrapped in a skipError naming the gap.
+//A read that got back to the mark before running out missed nothing after it, so there is nothing to skip and no error.
+func skipAhead(mark *highWaterMark, records []listedRecord, cutoff time.Time, err error) error {
+	to := cutoff
+	if len(records) > 0 && records[0].created.Before(cutoff) {
+		to = records[0].created
+	}
+	if !to.After(mark.at) {
+		return nil
+	}
+	skipped := &skipError{from: mark.at, to: to, err: err}
+	mark.skipTo(to)
+	return skipped
+}
+
+//listedRecord is a record of an incrementally read list, value holds the decoded record itself
+type listedRecord struct {
+	sid     string
+	created time.Time
+	value   interface{}
+}
+
+//readBack pages through a newest-first list and returns every record created at or after since, oldest first.
+//decode turns a page into its records, paging stops at the first page holding an older record, as every later page holds only older ones.
+//Running out of max_pages before reaching that page is an error, returned along with the newest records that were read, so callers never mistake a truncated list for a complete one.
+func (t *twilioClient) readBack(reqURL string, since time.Time, decode func(body []byte) ([]listedRecord, error)) ([]listedRecord, error) {
+	var records []listedRecord
+	err := t.forEachPage(reqURL, func(body []byte) error {
+		page, err := decode(body)
+		if err != nil {
+			return err
+		}
+		older := false
+		for _, record := range page {
+			if record.created.Before(since) {
+				older = true
+				continue
+			}
+			records = append(records, record)
+		}
+		if older {
+			return errStopPaging
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errPageLimit) {
+		return nil, err
+	}
+
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, err
+}
+
+//readSince pages through a newest-first list and returns the records created after mark and no later than cutoff, oldest first.
+//Any other failure returns nothing and leaves the mark alone, so the next read picks up the same records.
+//When max_pages runs out first, the mark skips ahead to the records that were read, which are returned along with the skipError, so a backlog too long to read costs one gap instead of stalling every later read.
+func (t *twilioClient) readSince(reqURL string, mark *highWaterMark, cutoff time.Time, decode func(body []byte) ([]listedRecord, error)) ([]listedRecord, error) {
+	records, err := t.readBack(reqURL, mark.at, decode)
+	if errors.Is(err, errPageLimit) {
+		err = skipAhead(mark, records, cutoff, err)
+	} else if err != nil {
+		return nil, err
+	}
+	var fresh []listedRecord
+	for _, record := range records {
+		if !record.created.After(cutoff) && mark.isNew(record.sid, record.created) {
+			fresh = append(fresh, record)
+		}
+	}
+	return fresh, err
+}
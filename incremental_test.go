@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const testAccount = "AC0123456789abcdef0123456789abcdef"
+
+//messageList serves a newest-first Messages list of messages in pages of PageSize, along with the account itself
+func messageList(t *testing.T, messages *[]MessageRecords) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/2010-04-01/Accounts/"+testAccount+".json" {
+			json.NewEncoder(w).Encode(AccountRecords{Sid: testAccount})
+			return
+		}
+		size, _ := strconv.Atoi(r.URL.Query().Get("PageSize"))
+		n, _ := strconv.Atoi(r.URL.Query().Get("Page"))
+		all := *messages
+		from, to := n*size, (n+1)*size
+		if from > len(all) {
+			from = len(all)
+		}
+		if to > len(all) {
+			to = len(all)
+		}
+		body := Messages{Messages: all[from:to]}
+		if to < len(all) {
+			body.NextPageURI = r.URL.Path + "?PageSize=" + strconv.Itoa(size) + "&Page=" + strconv.Itoa(n+1)
+		}
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			t.Error(err)
+		}
+	}))
+}
+
+//testClient returns a client for srv reading at most maxPages pages of pageSize records
+func testClient(t *testing.T, srv *httptest.Server, pageSize int, maxPages int) *twilioClient {
+	cfg := defaultConfig()
+	cfg.APIURL = srv.URL
+	cfg.PageSize = pageSize
+	cfg.MaxPages = maxPages
+	cfg.Retry.MaxRetries = 0
+	cfg.Accounts = []AccountConfig{{AccountSid: testAccount, Credentials: CredentialsConfig{AuthToken: "0123456789abcdef0123456789abcdef"}}}
+	source, err := cfg.Accounts[0].source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTwilioClient(source, cfg)
+}
+
+//message returns a delivered message sid created at created
+func message(sid string, created time.Time) MessageRecords {
+	return MessageRecords{Sid: sid, AccountSid: testAccount, DateCreated: created.Format(twilioTime), Status: "delivered", Direction: "outbound-api"}
+}
+
+func TestHighWaterMark(t *testing.T) {
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	mark := newHighWaterMark(at)
+	if mark.isNew("SM0", at.Add(-time.Second)) {
+		t.Error("a record older than the mark is new")
+	}
+	if !mark.isNew("SM1", at) {
+		t.Error("an uncounted record at the mark isn't new")
+	}
+	mark.advance("SM1", at)
+	if mark.isNew("SM1", at) {
+		t.Error("a counted record at the mark is new")
+	}
+	mark.advance("SM2", at.Add(time.Second))
+	if !mark.at.Equal(at.Add(time.Second)) || mark.isNew("SM2", at.Add(time.Second)) || !mark.isNew("SM3", at.Add(time.Second)) {
+		t.Errorf("advancing to a newer record left the mark at %s with %v", mark.at, mark.sids)
+	}
+}
+
+func TestReadSinceSkipsAheadAtPageLimit(t *testing.T) {
+	at := time.Now().Add(-time.Hour).Truncate(time.Second)
+	messages := []MessageRecords{message("SM3", at.Add(3*time.Second)), message("SM2", at.Add(2*time.Second)), message("SM1", at.Add(time.Second))}
+	srv := messageList(t, &messages)
+	defer srv.Close()
+	client := testClient(t, srv, 2, 1)
+	reqURL := client.listURL("/Accounts/"+testAccount+"/Messages.json", nil)
+	mark := newHighWaterMark(at)
+
+	fresh, err := client.readSince(reqURL, mark, time.Now(), decodeMessages)
+	var skipped *skipError
+	if !errors.As(err, &skipped) || !errors.Is(err, errPageLimit) || errorReason(err) != reasonPageLimit {
+		t.Fatalf("reading past max_pages returned %v, want a page_limit skipError", err)
+	}
+	if !skipped.from.Equal(at) || !skipped.to.Equal(at.Add(2*time.Second)) {
+		t.Errorf("skipped from %s to %s, want %s to %s", skipped.from, skipped.to, at, at.Add(2*time.Second))
+	}
+	if len(fresh) != 2 || fresh[0].sid != "SM2" || fresh[1].sid != "SM3" {
+		t.Fatalf("read %v, want SM2 and SM3 oldest first", fresh)
+	}
+	for _, record := range fresh {
+		mark.advance(record.sid, record.created)
+	}
+
+	messages = append([]MessageRecords{message("SM4", at.Add(4*time.Second))}, messages...)
+	fresh, err = client.readSince(reqURL, mark, time.Now(), decodeMessages)
+	if err != nil {
+		t.Fatalf("reading after skipping ahead: %v", err)
+	}
+	if len(fresh) != 1 || fresh[0].sid != "SM4" {
+		t.Errorf("read %v after skipping ahead, want SM4", fresh)
+	}
+}
+
+func TestReadSinceKeepsMarkOnFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"code":20500,"message":"Internal Server Error"}`, http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	client := testClient(t, srv, 2, 1)
+	at := time.Now().Add(-time.Hour)
+	mark := newHighWaterMark(at)
+
+	fresh, err := client.readSince(client.listURL("/Accounts/"+testAccount+"/Messages.json", nil), mark, time.Now(), decodeMessages)
+	if err == nil || fresh != nil {
+		t.Fatalf("a failed read returned %v, %v", fresh, err)
+	}
+	if !mark.at.Equal(at) {
+		t.Errorf("a failed read moved the mark to %s", mark.at)
+	}
+}
+
+func TestMessagesCollectorMakesProgressPastPageLimit(t *testing.T) {
+	at := time.Now().Add(-time.Hour).Truncate(time.Second)
+	messages := []MessageRecords{message("SM3", at.Add(3*time.Second)), message("SM2", at.Add(2*time.Second)), message("SM1", at.Add(time.Second))}
+	srv := messageList(t, &messages)
+	defer srv.Close()
+	c := newMessagesCollector([]accountTarget{newAccountTarget(testAccount, testClient(t, srv, 2, 1))}, 0)
+	c.marks[testAccount] = newHighWaterMark(at)
+
+	delivered := c.messages.WithLabelValues(testAccount, "delivered", "outbound-api", "", "")
+	for poll := 0; poll < 3; poll++ {
+		c.collectAccount(make(chan<- prometheus.Metric, 10), c.targets[0].client, AccountRecords{Sid: testAccount})
+	}
+	if mark := c.marks[testAccount]; !mark.at.Equal(at.Add(3 * time.Second)) {
+		t.Errorf("the mark stayed at %s, want %s", mark.at, at.Add(3*time.Second))
+	}
+	if count := testutil.ToFloat64(delivered); count != 2 {
+		t.Errorf("counted %v messages, want the 2 read before the skip", count)
+	}
+}
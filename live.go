@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//Conferences is the outside object from Twilios Conferences api
+type Conferences struct {
+	Conferences []ConferenceRecords `json:"conferences"`
+	NextPageURI string              `json:"next_page_uri"`
+}
+
+//ConferenceRecords are the inside objects from Twilios Conferences api
+type ConferenceRecords struct {
+	Sid          string `json:"sid"`
+	AccountSid   string `json:"account_sid"`
+	FriendlyName string `json:"friendly_name"`
+	Status       string `json:"status"`
+}
+
+//Participants is the outside object from Twilios conference Participants api
+type Participants struct {
+	Participants []ParticipantRecords `json:"participants"`
+	NextPageURI  string               `json:"next_page_uri"`
+}
+
+//ParticipantRecords are the inside objects from Twilios conference Participants api
+type ParticipantRecords struct {
+	CallSid       string `json:"call_sid"`
+	ConferenceSid string `json:"conference_sid"`
+	Status        string `json:"status"`
+}
+
+//liveCallStatuses are the statuses of calls that haven't ended yet
+var liveCallStatuses = []string{"queued", "ringing", "in-progress"}
+
+//countCalls returns the number of calls of account currently in status
+func (t *twilioClient) countCalls(account string, status string) (int, error) {
+	count := 0
+	err := t.forEachPage(t.listURL("/Accounts/"+account+"/Calls.json", url.Values{"Status": {status}}), func(body []byte) error {
+		var bodyObject Calls
+		if err := json.Unmarshal(body, &bodyObject); err != nil {
+			return err
+		}
+		count += len(bodyObject.Calls)
+		return nil
+	})
+	return count, err
+}
+
+//fetchActiveConferences returns every conference of account that is in progress
+func (t *twilioClient) fetchActiveConferences(account string) ([]ConferenceRecords, error) {
+	var conferences []ConferenceRecords
+	err := t.forEachPage(t.listURL("/Accounts/"+account+"/Conferences.json", url.Values{"Status": {"in-progress"}}), func(body []byte) error {
+		var bodyObject Conferences
+		if err := json.Unmarshal(body, &bodyObject); err != nil {
+			return err
+		}
+		conferences = append(conferences, bodyObject.Conferences...)
+		return nil
+	})
+	return conferences, err
+}
+
+//countParticipants returns the number of participants in conference of account
+func (t *twilioClient) countParticipants(account string, conference string) (int, error) {
+	count := 0
+	err := t.forEachPage(t.listURL("/Accounts/"+account+"/Conferences/"+conference+"/Participants.json", nil), func(body []byte) error {
+		var bodyObject Participants
+		if err := json.Unmarshal(body, &bodyObject); err != nil {
+			return err
+		}
+		count += len(bodyObject.Participants)
+		return nil
+	})
+	return count, err
+}
+
+//LiveCollector exports the calls and conferences in progress right now, to watch concurrency against trunk and CPS limits
+type LiveCollector struct {
+	targets      []accountTarget
+	calls        *prometheus.Desc
+	conferences  *prometheus.Desc
+	participants *prometheus.Desc
+}
+
+//newLiveCollector creates a collector for the live calls and conferences of targets
+func newLiveCollector(targets []accountTarget) *LiveCollector {
+	return &LiveCollector{
+		targets:      targets,
+		calls:        prometheus.NewDesc("twil_calls_current", "Calls that are queued, ringing or in progress right now", []string{"account_sid", "account_name", "status"}, nil),
+		conferences:  prometheus.NewDesc("twil_conferences_current", "Conferences in progress right now", []string{"account_sid", "account_name"}, nil),
+		participants: prometheus.NewDesc("twil_conference_participants", "Participants of a conference in progress", []string{"account_sid", "account_name", "sid", "friendly_name"}, nil),
+	}
+}
+
+//Describe initializes channels used to pull Metrics
+func (c *LiveCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.calls
+	ch <- c.conferences
+	ch <- c.participants
+}
+
+//Collect gathers the metrics
+func (c *LiveCollector) Collect(ch chan<- prometheus.Metric) {
+	eachAccount(ch, c.targets, "live", c.calls, c.collectAccount)
+}
+
+//collectAccount emits the live calls and conferences of account and records the health of the poll
+func (c *LiveCollector) collectAccount(ch chan<- prometheus.Metric, client *twilioClient, account AccountRecords) {
+	start := time.Now()
+	var pollErr error
+	for _, status := range liveCallStatuses {
+		count, err := client.countCalls(account.Sid, status)
+		if err != nil {
+			pollErr = err
+			reportError(ch, c.calls, fmt.Errorf("counting %s calls of %s: %w", status, account.Sid, err))
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.calls, prometheus.GaugeValue, float64(count), account.Sid, account.FriendlyName, status)
+	}
+
+	conferences, err := client.fetchActiveConferences(account.Sid)
+	if err != nil {
+		reportError(ch, c.conferences, fmt.Errorf("fetching conferences of %s: %w", account.Sid, err))
+		recordPoll(account.Sid, "live", start, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.conferences, prometheus.GaugeValue, float64(len(conferences)), account.Sid, account.FriendlyName)
+	for _, conference := range conferences {
+		count, err := client.countParticipants(account.Sid, conference.Sid)
+		if err != nil {
+			pollErr = err
+			reportError(ch, c.participants, fmt.Errorf("counting participants of conference %s of %s: %w", conference.Sid, account.Sid, err))
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.participants, prometheus.GaugeValue, float64(count), account.Sid, account.FriendlyName, conference.Sid, conference.FriendlyName)
+	}
+	recordPoll(account.Sid, "live", start, pollErr)
+}
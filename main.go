@@ -1,29 +1,161 @@
-package main
-
-import (
-	"flag"
-	"net/http"
-
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-)
-
-//Account - account token for Twilio
-var Account = flag.String("account", "", "The port metrics are exposed on")
-
-//Token - access token for Twilio
-var Token = flag.String("token", "", "The port metrics are exposed on")
-
-//Port - Port metrics are exposed on, include the colon. E.G. :2112
-var Port = flag.String("port", ":2112", "The port metrics are exposed on")
-
-func main() {
-
-	flag.Parse()
-
-	usage := newUsageCollector()
-	prometheus.MustRegister(usage)
-
-	http.Handle("/metrics", promhttp.Handler())
-	http.ListenAndServe(*Port, nil)
-}
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+//ConfigFile - YAML configuration file, when set the other flags are ignored
+var ConfigFile = flag.String("config", "", "Path to a YAML configuration file, reloaded on SIGHUP or when it changes. When set the other flags are ignored")
+
+//Account - Account SID for Twilio, always required, falls back to $TWILIO_ACCOUNT_SID
+var Account = flag.String("account", "", "The Twilio Account SID, defaults to $TWILIO_ACCOUNT_SID")
+
+//Token - Auth Token for Twilio, not needed when using an API Key, falls back to $TWILIO_AUTH_TOKEN
+var Token = flag.String("token", "", "The Twilio Auth Token of -account, defaults to $TWILIO_AUTH_TOKEN. Prefer -token-file, flags are visible in ps")
+
+//TokenFile - file holding the Auth Token, re-read when it changes, falls back to $TWILIO_AUTH_TOKEN_FILE
+var TokenFile = flag.String("token-file", "", "File containing the Twilio Auth Token, re-read when it changes, defaults to $TWILIO_AUTH_TOKEN_FILE")
+
+//APIKey - API Key SID, used with APISecret instead of the Auth Token, falls back to $TWILIO_API_KEY
+var APIKey = flag.String("api-key", "", "A Twilio API Key SID to authenticate with instead of the Auth Token, defaults to $TWILIO_API_KEY")
+
+//APISecret - secret of APIKey, falls back to $TWILIO_API_SECRET
+var APISecret = flag.String("api-secret", "", "The secret of -api-key, defaults to $TWILIO_API_SECRET. Prefer -api-secret-file, flags are visible in ps")
+
+//APISecretFile - file holding the API Secret, re-read when it changes, falls back to $TWILIO_API_SECRET_FILE
+var APISecretFile = flag.String("api-secret-file", "", "File containing the secret of -api-key, re-read when it changes, defaults to $TWILIO_API_SECRET_FILE")
+
+//Port - Port metrics are exposed on, include the colon. E.G. :2112
+var Port = flag.String("port", ":2112", "The port metrics are exposed on")
+
+//MaxRetries - how many times a throttled or failed Twilio request is retried
+var MaxRetries = flag.Int("max-retries", 3, "How many times a throttled, 5xx or network failed Twilio request is retried")
+
+//PageSize - number of usage records requested per page from Twilio, max 1000
+var PageSize = flag.Int("page-size", 1000, "The number of usage records requested per page")
+
+//MaxPages - upper bound on pages walked per list per poll, 0 for no limit
+var MaxPages = flag.Int("max-pages", 20, "The maximum number of pages fetched per list per poll, 0 for no limit")
+
+//Periods - comma separated usage periods to collect, see usagePeriods
+var Periods = flag.String("periods", "alltime", "Comma separated usage periods to collect: today, yesterday, thismonth, lastmonth, daily, monthly, yearly, alltime")
+
+//UsageInterval - how often usage records are polled from Twilio, scrapes are served from the latest poll
+var UsageInterval = flag.Duration("usage-interval", 5*time.Minute, "How often usage records are polled from Twilio in the background")
+
+//BalanceEnabled - export twil_account_balance for every collected account
+var BalanceEnabled = flag.Bool("balance", false, "Export the balance of every collected account as twil_account_balance")
+
+//MessagesEnabled - count messages by status, direction and error code from the Messages list
+var MessagesEnabled = flag.Bool("messages", false, "Count new messages by status, direction and error code as twil_messages_total")
+
+//CallsEnabled - count calls by outcome and observe their duration and price from the Calls list
+var CallsEnabled = flag.Bool("calls", false, "Count ended calls by status and direction and observe their duration and price")
+
+//PhoneNumbersEnabled - export the incoming phone number inventory
+var PhoneNumbersEnabled = flag.Bool("phone-numbers", false, "Export the incoming phone numbers of every collected account with their capabilities and configuration")
+
+//PhoneNumbersMode - info or count
+var PhoneNumbersMode = flag.String("phone-numbers-mode", "info", "info exports twil_phone_number_info per number, count exports twil_phone_numbers per type, capabilities and configuration")
+
+//QueuesEnabled - export the depth and wait time of call queues
+var QueuesEnabled = flag.Bool("queues", false, "Export the current size, max size and average wait time of every call queue, polled every 15s")
+
+//LiveEnabled - export the calls and conferences in progress
+var LiveEnabled = flag.Bool("live", false, "Export the calls queued, ringing and in progress and the active conferences with their participants, polled every 15s")
+
+//AlertsEnabled - count debugger alerts by error code
+var AlertsEnabled = flag.Bool("alerts", false, "Count new debugger alerts from the Monitor api by error code, log level and resource type as twil_alerts_total")
+
+//EventsEnabled - count Monitor audit events
+var EventsEnabled = flag.Bool("events", false, "Count new Monitor audit events by event type, resource type and actor type as twil_events_total")
+
+//EventsLog - log every new audit event
+var EventsLog = flag.Bool("events-log", false, "With -events, also write every new audit event to stdout as a line of JSON")
+
+//TaskRouterEnabled - export TaskRouter real-time statistics
+var TaskRouterEnabled = flag.Bool("taskrouter", false, "Export the real-time statistics of every TaskRouter workspace, workflow and task queue, polled every 30s")
+
+//WebhooksEnabled - receive status callbacks
+var WebhooksEnabled = flag.Bool("webhooks", false, "Receive Twilio status callbacks at /callbacks/messages and /callbacks/calls, checked against the Auth Token")
+
+//WebhooksURL - the public base URL of twil
+var WebhooksURL = flag.String("webhooks-url", "", "The base URL Twilio calls twil at, needed to check callback signatures behind a proxy")
+
+//EventStreamsEnabled - receive Event Streams deliveries
+var EventStreamsEnabled = flag.Bool("event-streams", false, "Count the CloudEvents Twilio Event Streams delivers to /callbacks/events")
+
+//EventStreamsMapping - optional
+var EventStreamsMapping = flag.String("event-streams-mapping", "", "Path to a JSON file mapping event types to counters labeled by event attributes")
+
+//Categories - optional JSON file of extra or overriding usage categories
+var Categories = flag.String("categories", "", "Path to a JSON file of usage categories to add to or override the built-in catalog")
+
+//LegacyMetrics - keep exporting catalog categories under their own metric names, e.g. twil_sms_inbound_shortcode
+var LegacyMetrics = flag.Bool("legacy-metrics", true, "Export catalog categories under their per-category metric names")
+
+//LabeledMetrics - export every record through twil_usage_count, twil_usage_amount and twil_usage_price
+var LabeledMetrics = flag.Bool("labeled-metrics", false, "Export every usage record as twil_usage_count, twil_usage_amount and twil_usage_price labeled by category")
+
+//Subaccounts - collect every active account listed under the -account credentials instead of just -account
+var Subaccounts = flag.Bool("subaccounts", false, "Discover and collect usage for every active subaccount of -account")
+
+//SubaccountFilter - only collect discovered accounts whose SID or FriendlyName matches this regex
+var SubaccountFilter = flag.String("subaccount-filter", "", "Regex matched against the SID or FriendlyName of discovered subaccounts")
+
+//orEnv returns value, or the environment variable name when value is empty
+func orEnv(value string, name string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(name)
+}
+
+func main() {
+
+	flag.Parse()
+
+	var cfg *Config
+	var err error
+	if *ConfigFile != "" {
+		cfg, err = loadConfig(*ConfigFile)
+	} else {
+		cfg = configFromFlags()
+		err = cfg.validate()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	usage, sources, err := buildCollector(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	current := &reloadableCollector{cfg: cfg, current: usage, sources: sources}
+	prometheus.MustRegister(current)
+	prometheus.MustRegister(healthCollectors...)
+	prometheus.MustRegister(webhookCollectors...)
+
+	if *ConfigFile != "" {
+		go watchConfig(*ConfigFile, cfg, current)
+	}
+
+	//ContinueOnError serves everything that was collected and logs the invalid metrics of failed polls instead of failing the whole scrape
+	handler := promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+		ErrorLog:      log.New(os.Stderr, "", log.LstdFlags),
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+	http.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, handler))
+	http.Handle("/probe", probeHandler(current))
+	http.Handle("/callbacks/messages", webhookHandler(current, "messages"))
+	http.Handle("/callbacks/calls", webhookHandler(current, "calls"))
+	http.Handle("/callbacks/events", eventStreamHandler(current))
+	log.Fatal(http.ListenAndServe(cfg.ListenAddress, nil))
+}
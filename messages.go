@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//Messages is the outside object from Twilios Messages api
+type Messages struct {
+	Messages    []MessageRecords `json:"messages"`
+	NextPageURI string           `json:"next_page_uri"`
+}
+
+//MessageRecords are the inside objects from Twilios Messages api
+type MessageRecords struct {
+	Sid                 string `json:"sid"`
+	AccountSid          string `json:"account_sid"`
+	DateCreated         string `json:"date_created"`
+	Status              string `json:"status"`
+	Direction           string `json:"direction"`
+	ErrorCode           *int   `json:"error_code"`
+	MessagingServiceSid string `json:"messaging_service_sid"`
+}
+
+//errorCode formats a nullable Twilio error code as a label value, empty when there is none
+func errorCode(code *int) string {
+	if code == nil {
+		return ""
+	}
+	return strconv.Itoa(*code)
+}
+
+//MessagesCollector counts messages by status as they are created, reading /Messages.json incrementally from a high-water mark on DateCreated.
+//Messages are only counted once they are older than settle, giving them time to reach a final status.
+type MessagesCollector struct {
+	targets  []accountTarget
+	settle   time.Duration
+	messages *prometheus.CounterVec
+
+	mu    sync.Mutex
+	marks map[string]*highWaterMark
+}
+
+//newMessagesCollector creates a collector counting messages of targets created from now on
+func newMessagesCollector(targets []accountTarget, settle time.Duration) *MessagesCollector {
+	return &MessagesCollector{
+		targets: targets,
+		settle:  settle,
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "twil_messages_total",
+			Help: "Messages created since twil started, by status once settled, direction, error code and messaging service",
+		}, []string{"account_sid", "status", "direction", "error_code", "messaging_service_sid"}),
+		marks: map[string]*highWaterMark{},
+	}
+}
+
+//Describe initializes channels used to pull Metrics
+func (c *MessagesCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.messages.Describe(ch)
+}
+
+//Collect reads the messages created since the last poll and gathers the counters
+func (c *MessagesCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	eachAccount(ch, c.targets, "messages", messagesErrorDesc, c.collectAccount)
+	c.messages.Collect(ch)
+}
+
+//messagesErrorDesc identifies the invalid metrics of failed message polls
+var messagesErrorDesc = prometheus.NewDesc("twil_messages_total", "Messages created since twil started", nil, nil)
+
+//collectAccount counts the settled messages of account created since its high-water mark and records the health of the poll
+func (c *MessagesCollector) collectAccount(ch chan<- prometheus.Metric, client *twilioClient, account AccountRecords) {
+	start := time.Now()
+	cutoff := start.Add(-c.settle)
+	mark, ok := c.marks[account.Sid]
+	if !ok {
+		mark = newHighWaterMark(cutoff)
+		c.marks[account.Sid] = mark
+	}
+
+	fresh, err := client.readSince(client.listURL("/Accounts/"+account.Sid+"/Messages.json", nil), mark, cutoff, decodeMessages)
+	for _, record := range fresh {
+		message := record.value.(MessageRecords)
+		mark.advance(record.sid, record.created)
+		c.messages.WithLabelValues(account.Sid, message.Status, message.Direction, errorCode(message.ErrorCode), message.MessagingServiceSid).Inc()
+	}
+	if err != nil {
+		reportError(ch, messagesErrorDesc, fmt.Errorf("reading messages of %s: %w", account.Sid, err))
+	}
+	recordPoll(account.Sid, "messages", start, err)
+}
+
+//decodeMessages decodes a page of the Messages list for readSince
+func decodeMessages(body []byte) ([]listedRecord, error) {
+	var bodyObject Messages
+	if err := json.Unmarshal(body, &bodyObject); err != nil {
+		return nil, err
+	}
+	records := make([]listedRecord, 0, len(bodyObject.Messages))
+	for _, message := range bodyObject.Messages {
+		created, err := time.Parse(twilioTime, message.DateCreated)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, listedRecord{sid: message.Sid, created: created, value: message})
+	}
+	return records, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//IncomingPhoneNumbers is the outside object from Twilios IncomingPhoneNumbers api
+type IncomingPhoneNumbers struct {
+	IncomingPhoneNumbers []IncomingPhoneNumberRecords `json:"incoming_phone_numbers"`
+	NextPageURI          string                       `json:"next_page_uri"`
+}
+
+//IncomingPhoneNumberRecords are the inside objects from Twilios IncomingPhoneNumbers api
+type IncomingPhoneNumberRecords struct {
+	Sid                 string `json:"sid"`
+	AccountSid          string `json:"account_sid"`
+	PhoneNumber         string `json:"phone_number"`
+	FriendlyName        string `json:"friendly_name"`
+	VoiceURL            string `json:"voice_url"`
+	VoiceApplicationSid string `json:"voice_application_sid"`
+	TrunkSid            string `json:"trunk_sid"`
+	SmsURL              string `json:"sms_url"`
+	SmsApplicationSid   string `json:"sms_application_sid"`
+	Capabilities        struct {
+		Voice bool `json:"voice"`
+		SMS   bool `json:"sms"`
+		MMS   bool `json:"mms"`
+	} `json:"capabilities"`
+}
+
+//phoneNumberTypes maps the type label to the IncomingPhoneNumbers subresource listing numbers of that type, named like the phonenumbers-* usage categories.
+//Numbers in none of them, such as national or shared cost numbers, are labeled otherPhoneNumberType.
+var phoneNumberTypes = map[string]string{
+	"local":    "Local",
+	"mobile":   "Mobile",
+	"tollfree": "TollFree",
+}
+
+//otherPhoneNumberType is the type label of numbers not listed by any of the phoneNumberTypes subresources
+const otherPhoneNumberType = "other"
+
+//phoneNumberModes are the accepted values of collectors.phone_numbers.mode
+var phoneNumberModes = map[string]bool{"info": true, "count": true}
+
+//phoneNumberLabels are the labels describing how a number is set up, shared by the info and count metrics
+var phoneNumberLabels = []string{"type", "voice", "sms", "mms", "voice_url_set", "sms_url_set"}
+
+//fetchPhoneNumbers returns every incoming phone number of account, or only those of subresource such as Local when it is set
+func (t *twilioClient) fetchPhoneNumbers(account string, subresource string) ([]IncomingPhoneNumberRecords, error) {
+	path := "/Accounts/" + account + "/IncomingPhoneNumbers.json"
+	if subresource != "" {
+		path = "/Accounts/" + account + "/IncomingPhoneNumbers/" + subresource + ".json"
+	}
+	var numbers []IncomingPhoneNumberRecords
+	err := t.forEachPage(t.listURL(path, nil), func(body []byte) error {
+		var bodyObject IncomingPhoneNumbers
+		if err := json.Unmarshal(body, &bodyObject); err != nil {
+			return err
+		}
+		numbers = append(numbers, bodyObject.IncomingPhoneNumbers...)
+		return nil
+	})
+	return numbers, err
+}
+
+//PhoneNumbersCollector exports the incoming phone number inventory, either one twil_phone_number_info series per number or twil_phone_numbers counts of numbers set up alike.
+//voice_url_set also counts numbers routed by a voice application or SIP trunk, and sms_url_set numbers routed by a messaging application, so only numbers going nowhere are reported as unset.
+type PhoneNumbersCollector struct {
+	targets   []accountTarget
+	aggregate bool
+	info      *prometheus.Desc
+	count     *prometheus.Desc
+}
+
+//newPhoneNumbersCollector creates a collector for the phone numbers of targets, mode is info or count
+func newPhoneNumbersCollector(targets []accountTarget, mode string) *PhoneNumbersCollector {
+	return &PhoneNumbersCollector{
+		targets:   targets,
+		aggregate: mode == "count",
+		info:      prometheus.NewDesc("twil_phone_number_info", "Incoming phone number, always 1", withLabels([]string{"account_sid", "account_name", "sid", "phone_number", "friendly_name"}, phoneNumberLabels...), nil),
+		count:     prometheus.NewDesc("twil_phone_numbers", "Incoming phone numbers by type, capabilities and configuration", withLabels([]string{"account_sid", "account_name"}, phoneNumberLabels...), nil),
+	}
+}
+
+//desc returns the description of the metric exported in the configured mode
+func (c *PhoneNumbersCollector) desc() *prometheus.Desc {
+	if c.aggregate {
+		return c.count
+	}
+	return c.info
+}
+
+//Describe initializes channels used to pull Metrics
+func (c *PhoneNumbersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc()
+}
+
+//Collect gathers the metrics
+func (c *PhoneNumbersCollector) Collect(ch chan<- prometheus.Metric) {
+	eachAccount(ch, c.targets, "phone_numbers", c.desc(), c.collectAccount)
+}
+
+//collectAccount emits every phone number of account and records the health of the poll.
+//The numbers come from the full IncomingPhoneNumbers list, the type subresources only tell their type, so a failed type listing skips the account rather than mislabel its numbers.
+func (c *PhoneNumbersCollector) collectAccount(ch chan<- prometheus.Metric, client *twilioClient, account AccountRecords) {
+	start := time.Now()
+	numbers, err := client.fetchPhoneNumbers(account.Sid, "")
+	if err != nil {
+		reportError(ch, c.desc(), fmt.Errorf("fetching phone numbers of %s: %w", account.Sid, err))
+		recordPoll(account.Sid, "phone_numbers", start, err)
+		return
+	}
+	types := map[string]string{}
+	for numberType, subresource := range phoneNumberTypes {
+		typed, err := client.fetchPhoneNumbers(account.Sid, subresource)
+		if err != nil {
+			reportError(ch, c.desc(), fmt.Errorf("fetching %s phone numbers of %s: %w", numberType, account.Sid, err))
+			recordPoll(account.Sid, "phone_numbers", start, err)
+			return
+		}
+		for _, number := range typed {
+			types[number.Sid] = numberType
+		}
+	}
+
+	counts := map[[6]string]float64{}
+	for _, number := range numbers {
+		numberType, ok := types[number.Sid]
+		if !ok {
+			numberType = otherPhoneNumberType
+		}
+		labels := phoneNumberLabelValues(numberType, number)
+		if c.aggregate {
+			counts[labels]++
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.info, prometheus.GaugeValue, 1, withLabels([]string{account.Sid, account.FriendlyName, number.Sid, number.PhoneNumber, number.FriendlyName}, labels[:]...)...)
+	}
+	for labels, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.count, prometheus.GaugeValue, count, withLabels([]string{account.Sid, account.FriendlyName}, labels[:]...)...)
+	}
+	recordPoll(account.Sid, "phone_numbers", start, nil)
+}
+
+//phoneNumberLabelValues returns the values of phoneNumberLabels for a number of numberType
+func phoneNumberLabelValues(numberType string, number IncomingPhoneNumberRecords) [6]string {
+	return [6]string{
+		numberType,
+		strconv.FormatBool(number.Capabilities.Voice),
+		strconv.FormatBool(number.Capabilities.SMS),
+		strconv.FormatBool(number.Capabilities.MMS),
+		strconv.FormatBool(number.VoiceURL != "" || number.VoiceApplicationSid != "" || number.TrunkSid != ""),
+		strconv.FormatBool(number.SmsURL != "" || number.SmsApplicationSid != ""),
+	}
+}
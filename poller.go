@@ -0,0 +1,148 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//snapshotAgeDesc reports how old the snapshot served for an endpoint is
+var snapshotAgeDesc = prometheus.NewDesc("twil_snapshot_age_seconds", "Seconds since the cached metrics of an endpoint were last refreshed from Twilio", []string{"endpoint"}, nil)
+
+//cachedCollector polls inner in the background every interval and serves the latest snapshot to scrapes, so scrapes never call Twilio themselves
+type cachedCollector struct {
+	endpoint string
+	inner    prometheus.Collector
+	interval time.Duration
+
+	mu       sync.RWMutex
+	snapshot []prometheus.Metric
+	updated  time.Time
+
+	done chan struct{}
+}
+
+//newCachedCollector starts polling inner straight away and then every interval until Stop is called
+func newCachedCollector(endpoint string, inner prometheus.Collector, interval time.Duration) *cachedCollector {
+	c := &cachedCollector{
+		endpoint: endpoint,
+		inner:    inner,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+//run polls until Stop is called
+func (c *cachedCollector) run() {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	c.poll()
+	for {
+		select {
+		case <-ticker.C:
+			c.poll()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+//poll collects inner into a new snapshot, a panic while polling is logged and the previous snapshot kept
+func (c *cachedCollector) poll() {
+	ch := make(chan prometheus.Metric)
+	collected := make(chan []prometheus.Metric)
+	go func() {
+		var metrics []prometheus.Metric
+		for m := range ch {
+			metrics = append(metrics, m)
+		}
+		collected <- metrics
+	}()
+
+	panicked := false
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("polling %s panicked, keeping the previous snapshot: %v", c.endpoint, r)
+				panicked = true
+			}
+		}()
+		c.inner.Collect(ch)
+	}()
+	close(ch)
+	metrics := <-collected
+	if panicked {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.snapshot = metrics
+	c.updated = time.Now()
+}
+
+//Stop ends background polling
+func (c *cachedCollector) Stop() {
+	close(c.done)
+}
+
+//Describe passes on the descriptions of inner along with the snapshot age
+func (c *cachedCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.inner.Describe(ch)
+	ch <- snapshotAgeDesc
+}
+
+//Collect serves the latest snapshot, nothing is served until the first poll finishes
+func (c *cachedCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.updated.IsZero() {
+		return
+	}
+	for _, m := range c.snapshot {
+		ch <- m
+	}
+	ch <- prometheus.MustNewConstMetric(snapshotAgeDesc, prometheus.GaugeValue, time.Since(c.updated).Seconds(), c.endpoint)
+}
+
+//collectorGroup is the set of collectors built from one configuration
+type collectorGroup []prometheus.Collector
+
+//Describe describes every collector in the group
+func (g collectorGroup) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range g {
+		c.Describe(ch)
+	}
+}
+
+//Collect collects every collector in the group
+func (g collectorGroup) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range g {
+		c.Collect(ch)
+	}
+}
+
+//endpoints returns the endpoints polled by the collectors of the group
+func (g collectorGroup) endpoints() map[string]bool {
+	endpoints := make(map[string]bool, len(g))
+	for _, c := range g {
+		if cached, ok := c.(*cachedCollector); ok {
+			endpoints[cached.endpoint] = true
+		}
+	}
+	return endpoints
+}
+
+//Stop stops the background work of every collector in the group that has any
+func (g collectorGroup) Stop() {
+	for _, c := range g {
+		if s, ok := c.(interface{ Stop() }); ok {
+			s.Stop()
+		}
+	}
+}
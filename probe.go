@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+//probeHandler serves /probe?account=<sid>&module=<name>, collecting the usage of one account into a fresh registry on every request.
+//Credentials always come from the running configuration, never from the request. Like the blackbox_exporter the scrape timeout bounds the whole probe, not each request.
+func probeHandler(running *reloadableCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := running.config()
+		sid := r.URL.Query().Get("account")
+		moduleName := r.URL.Query().Get("module")
+
+		probeCfg, target, err := probeTarget(cfg, sid, moduleName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), scrapeTimeout(r, cfg.Timeout))
+		defer cancel()
+		retries := probeClient(ctx, target.client)
+
+		usage, err := newUsageCollector(probeCfg, []accountTarget{target})
+		if err != nil {
+			log.Printf("probe of %s: %v", sid, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(newProbeCollector(usage, retries))
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+			ErrorLog:      log.New(os.Stderr, "", log.LstdFlags),
+			ErrorHandling: promhttp.ContinueOnError,
+		}).ServeHTTP(w, r)
+	})
+}
+
+//probeCollector runs a probe with usage, reporting its outcome in twil_probe_success and twil_probe_duration_seconds like the blackbox_exporter's probe_success.
+//Probes leave the health metrics of /metrics alone, so probed accounts neither overwrite the polled ones nor linger there, and retries are collected once the probe is done.
+type probeCollector struct {
+	usage    *UsageCollector
+	retries  []prometheus.Collector
+	success  *prometheus.Desc
+	duration *prometheus.Desc
+	failed   bool
+}
+
+//newProbeCollector creates a collector probing with usage, taking over the recording of its polls, retries are the retry counters of its client
+func newProbeCollector(usage *UsageCollector, retries []prometheus.Collector) *probeCollector {
+	p := &probeCollector{
+		usage:    usage,
+		retries:  retries,
+		success:  prometheus.NewDesc("twil_probe_success", "Whether every request of the probe succeeded", nil, nil),
+		duration: prometheus.NewDesc("twil_probe_duration_seconds", "How long the probe took", nil, nil),
+	}
+	usage.record = p.recordPoll
+	usage.forget = func(string, map[string]bool) {}
+	return p
+}
+
+//Describe initializes channels used to pull Metrics
+func (p *probeCollector) Describe(ch chan<- *prometheus.Desc) {
+	p.usage.Describe(ch)
+	for _, c := range p.retries {
+		c.Describe(ch)
+	}
+	ch <- p.success
+	ch <- p.duration
+}
+
+//Collect runs the probe and gathers its metrics
+func (p *probeCollector) Collect(ch chan<- prometheus.Metric) {
+	start := time.Now()
+	p.failed = false
+	p.usage.Collect(ch)
+
+	success := 1.0
+	if p.failed {
+		success = 0
+	}
+	ch <- prometheus.MustNewConstMetric(p.success, prometheus.GaugeValue, success)
+	ch <- prometheus.MustNewConstMetric(p.duration, prometheus.GaugeValue, time.Since(start).Seconds())
+	for _, c := range p.retries {
+		c.Collect(ch)
+	}
+}
+
+//recordPoll notes a failed poll of the probe
+func (p *probeCollector) recordPoll(account string, endpoint string, start time.Time, err error) {
+	if err != nil {
+		p.failed = true
+	}
+}
+
+//probeClient binds the requests of client to the ctx of a probe and counts its retries in counters of its own, returned for the probe, instead of the health metrics
+func probeClient(ctx context.Context, client *twilioClient) []prometheus.Collector {
+	client.ctx = ctx
+	retries, throttled := newRetryCounters()
+	if transport, ok := client.client.Transport.(*retryTransport); ok {
+		transport.retries, transport.throttled = retries, throttled
+	}
+	return []prometheus.Collector{retries, throttled}
+}
+
+//probeTarget resolves the settings and credentials for probing sid with the named module, an empty name uses the top level settings
+func probeTarget(cfg *Config, sid string, moduleName string) (*Config, accountTarget, error) {
+	if !accountSidRE.MatchString(sid) {
+		return nil, accountTarget{}, fmt.Errorf("account parameter %q is not an Account SID", sid)
+	}
+
+	probeCfg := *cfg
+	credentialsFrom := ""
+	if moduleName != "" {
+		module, ok := cfg.Modules[moduleName]
+		if !ok {
+			return nil, accountTarget{}, fmt.Errorf("unknown module %q", moduleName)
+		}
+		if len(module.Periods) > 0 {
+			probeCfg.Periods = module.Periods
+		}
+		if module.Categories != nil {
+			probeCfg.Categories = *module.Categories
+		}
+		if module.Metrics != nil {
+			probeCfg.Metrics = *module.Metrics
+		}
+		credentialsFrom = module.CredentialsFrom
+	}
+
+	account, ok := cfg.account(sid)
+	if !ok && credentialsFrom != "" {
+		account, ok = cfg.account(credentialsFrom)
+	}
+	if !ok {
+		return nil, accountTarget{}, fmt.Errorf("no credentials configured for account %s", sid)
+	}
+
+	credentials, err := account.source()
+	if err != nil {
+		return nil, accountTarget{}, err
+	}
+	return &probeCfg, newAccountTarget(sid, newTwilioClient(credentials, &probeCfg)), nil
+}
+
+//scrapeTimeout returns the timeout Prometheus gave the scrape less a small margin, or fallback when that is unknown or longer
+func scrapeTimeout(r *http.Request, fallback time.Duration) time.Duration {
+	seconds, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64)
+	if err != nil || seconds <= 0 {
+		return fallback
+	}
+	timeout := time.Duration(seconds*float64(time.Second)) - 500*time.Millisecond
+	if timeout <= 0 || timeout > fallback {
+		return fallback
+	}
+	return timeout
+}
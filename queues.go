@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//Queues is the outside object from Twilios Queues api
+type Queues struct {
+	Queues      []QueueRecords `json:"queues"`
+	NextPageURI string         `json:"next_page_uri"`
+}
+
+//QueueRecords are the inside objects from Twilios Queues api
+type QueueRecords struct {
+	Sid             string  `json:"sid"`
+	AccountSid      string  `json:"account_sid"`
+	FriendlyName    string  `json:"friendly_name"`
+	CurrentSize     float64 `json:"current_size"`
+	MaxSize         float64 `json:"max_size"`
+	AverageWaitTime float64 `json:"average_wait_time"`
+}
+
+//queueLabels are the variable labels carried by every queue metric
+var queueLabels = []string{"account_sid", "account_name", "sid", "friendly_name"}
+
+//fetchQueues returns every queue of account
+func (t *twilioClient) fetchQueues(account string) ([]QueueRecords, error) {
+	var queues []QueueRecords
+	err := t.forEachPage(t.listURL("/Accounts/"+account+"/Queues.json", nil), func(body []byte) error {
+		var bodyObject Queues
+		if err := json.Unmarshal(body, &bodyObject); err != nil {
+			return err
+		}
+		queues = append(queues, bodyObject.Queues...)
+		return nil
+	})
+	return queues, err
+}
+
+//QueuesCollector exports the depth and wait time of every call queue
+type QueuesCollector struct {
+	targets     []accountTarget
+	currentSize *prometheus.Desc
+	maxSize     *prometheus.Desc
+	waitTime    *prometheus.Desc
+}
+
+//newQueuesCollector creates a collector for the queues of targets
+func newQueuesCollector(targets []accountTarget) *QueuesCollector {
+	return &QueuesCollector{
+		targets:     targets,
+		currentSize: prometheus.NewDesc("twil_queue_current_size", "Calls currently waiting in the queue", queueLabels, nil),
+		maxSize:     prometheus.NewDesc("twil_queue_max_size", "Maximum number of calls the queue holds", queueLabels, nil),
+		waitTime:    prometheus.NewDesc("twil_queue_average_wait_time_seconds", "Average time calls currently in the queue have waited", queueLabels, nil),
+	}
+}
+
+//Describe initializes channels used to pull Metrics
+func (c *QueuesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.currentSize
+	ch <- c.maxSize
+	ch <- c.waitTime
+}
+
+//Collect gathers the metrics
+func (c *QueuesCollector) Collect(ch chan<- prometheus.Metric) {
+	eachAccount(ch, c.targets, "queues", c.currentSize, c.collectAccount)
+}
+
+//collectAccount emits every queue of account and records the health of the poll
+func (c *QueuesCollector) collectAccount(ch chan<- prometheus.Metric, client *twilioClient, account AccountRecords) {
+	start := time.Now()
+	queues, err := client.fetchQueues(account.Sid)
+	if err != nil {
+		reportError(ch, c.currentSize, fmt.Errorf("fetching queues of %s: %w", account.Sid, err))
+	}
+	for _, queue := range queues {
+		ch <- prometheus.MustNewConstMetric(c.currentSize, prometheus.GaugeValue, queue.CurrentSize, account.Sid, account.FriendlyName, queue.Sid, queue.FriendlyName)
+		ch <- prometheus.MustNewConstMetric(c.maxSize, prometheus.GaugeValue, queue.MaxSize, account.Sid, account.FriendlyName, queue.Sid, queue.FriendlyName)
+		ch <- prometheus.MustNewConstMetric(c.waitTime, prometheus.GaugeValue, queue.AverageWaitTime, account.Sid, account.FriendlyName, queue.Sid, queue.FriendlyName)
+	}
+	recordPoll(account.Sid, "queues", start, err)
+}
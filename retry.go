@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//Retry metrics, registered with the health metrics
+var retriesCounter, throttledCounter = newRetryCounters()
+
+//newRetryCounters creates the counters of retried and throttled requests
+func newRetryCounters() (*prometheus.CounterVec, *prometheus.CounterVec) {
+	retries := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "twil_http_retries_total",
+		Help: "Twilio requests retried by reason: throttled, server_error or network",
+	}, []string{"account_sid", "reason"})
+	throttled := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "twil_http_throttled_total",
+		Help: "Twilio responses with status 429 Too Many Requests",
+	}, []string{"account_sid"})
+	return retries, throttled
+}
+
+//retryTransport retries idempotent requests that failed on the network, were throttled or hit a 5xx, with jittered exponential backoff.
+//A Retry-After header is honored, and no retry is attempted that would end past the deadline of the request.
+type retryTransport struct {
+	next       http.RoundTripper
+	account    string
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	retries    *prometheus.CounterVec
+	throttled  *prometheus.CounterVec
+}
+
+//newRetryTransport wraps http.DefaultTransport with the retry settings of cfg, counting retries against account in the health metrics
+func newRetryTransport(account string, cfg RetryConfig) *retryTransport {
+	return &retryTransport{
+		next:       http.DefaultTransport,
+		account:    account,
+		maxRetries: cfg.MaxRetries,
+		baseDelay:  cfg.BaseDelay,
+		maxDelay:   cfg.MaxDelay,
+		retries:    retriesCounter,
+		throttled:  throttledCounter,
+	}
+}
+
+//RoundTrip performs req, retrying as described on retryTransport
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := t.next.RoundTrip(req)
+
+		reason := retryReason(res, err)
+		if reason == "throttled" {
+			t.throttled.WithLabelValues(t.account).Inc()
+		}
+		if reason == "" || attempt >= t.maxRetries || (req.Method != "GET" && req.Method != "HEAD") {
+			return res, err
+		}
+
+		delay := t.backoff(attempt, res)
+		if deadline, ok := req.Context().Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return res, err
+		}
+		if res != nil {
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		t.retries.WithLabelValues(t.account, reason).Inc()
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+//retryReason returns why a response should be retried, or an empty string when it shouldn't be
+func retryReason(res *http.Response, err error) string {
+	switch {
+	case err != nil:
+		return "network"
+	case res.StatusCode == http.StatusTooManyRequests:
+		return "throttled"
+	case res.StatusCode >= 500:
+		return "server_error"
+	}
+	return ""
+}
+
+//backoff returns how long to wait before retrying, the Retry-After of res when it has one and otherwise a jittered exponential delay
+func (t *retryTransport) backoff(attempt int, res *http.Response) time.Duration {
+	if res != nil {
+		if after, ok := retryAfter(res.Header.Get("Retry-After")); ok {
+			return after
+		}
+	}
+
+	delay := t.baseDelay << uint(attempt)
+	if delay <= 0 || delay > t.maxDelay {
+		delay = t.maxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+//retryAfter parses a Retry-After header given in seconds or as an HTTP date
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
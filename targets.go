@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//accountRefresh is how long the accounts of a target are used before they are fetched or listed again
+const accountRefresh = 15 * time.Minute
+
+//accountTarget is a configured account to collect, along with its subaccounts when discover is set.
+//client may authenticate as a different account, such as the parent of sid.
+type accountTarget struct {
+	sid      string
+	client   *twilioClient
+	discover bool
+	filter   *regexp.Regexp
+	known    *knownAccounts
+}
+
+//knownAccounts are the accounts last fetched or listed for a target, shared by every collector of the target
+type knownAccounts struct {
+	mu      sync.Mutex
+	records []AccountRecords
+	fetched time.Time
+}
+
+//newAccountTarget creates a target for sid collected with client
+func newAccountTarget(sid string, client *twilioClient) accountTarget {
+	return accountTarget{sid: sid, client: client, known: &knownAccounts{}}
+}
+
+//accounts returns the accounts to collect for target, every matching active subaccount when discovery is on and otherwise just sid.
+//They are fetched or listed at most once per accountRefresh, and when a refresh fails the last good accounts are kept.
+func (t accountTarget) accounts() ([]AccountRecords, error) {
+	t.known.mu.Lock()
+	defer t.known.mu.Unlock()
+
+	if time.Since(t.known.fetched) < accountRefresh {
+		return t.known.records, nil
+	}
+	records, err := t.fetchAccounts()
+	if err != nil {
+		if t.known.fetched.IsZero() {
+			return nil, err
+		}
+		log.Printf("refreshing the accounts of %s, keeping the last good ones: %v", t.sid, err)
+		return t.known.records, nil
+	}
+	t.known.records, t.known.fetched = records, time.Now()
+	return records, nil
+}
+
+//fetchAccounts asks Twilio for the accounts of target
+func (t accountTarget) fetchAccounts() ([]AccountRecords, error) {
+	if t.discover {
+		return t.client.listAccounts(t.filter)
+	}
+	account, err := t.client.fetchAccount(t.sid)
+	if err != nil {
+		return nil, err
+	}
+	if account.Sid == "" {
+		account.Sid = t.sid
+	}
+	return []AccountRecords{account}, nil
+}
+
+//eachAccount calls collect once for every account of targets, skipping accounts already reached through an earlier target.
+//A target whose accounts can't be listed is reported against endpoint, with desc used for its invalid metric, and skipped so its failed poll stands.
+//The health metrics of accounts endpoint no longer reaches are dropped.
+func eachAccount(ch chan<- prometheus.Metric, targets []accountTarget, endpoint string, desc *prometheus.Desc, collect func(ch chan<- prometheus.Metric, client *twilioClient, account AccountRecords)) {
+	forgetAccounts(endpoint, eachAccountRecorded(ch, targets, endpoint, desc, recordPoll, collect))
+}
+
+//eachAccountRecorded is eachAccount with the failure to list the accounts of a target passed to record instead of the health metrics.
+//It returns the sids of the accounts it collected or failed to list, leaving the health metrics of the others alone.
+func eachAccountRecorded(ch chan<- prometheus.Metric, targets []accountTarget, endpoint string, desc *prometheus.Desc, record pollRecorder, collect func(ch chan<- prometheus.Metric, client *twilioClient, account AccountRecords)) map[string]bool {
+	seen := make(map[string]bool)
+	polled := make(map[string]bool)
+	for _, target := range targets {
+		start := time.Now()
+		accounts, err := target.accounts()
+		if err != nil {
+			err = fmt.Errorf("listing accounts of %s: %w", target.sid, err)
+			reportError(ch, desc, err)
+			record(target.sid, endpoint, start, err)
+			polled[target.sid] = true
+			continue
+		}
+
+		for _, account := range accounts {
+			if seen[account.Sid] {
+				continue
+			}
+			seen[account.Sid] = true
+			polled[account.Sid] = true
+			collect(ch, target.client, account)
+		}
+	}
+	return polled
+}
+
+//ownAccountSkips are the endpoints and accounts ownAccount has logged a skip for, guarded by ownAccountMu
+var (
+	ownAccountMu    sync.Mutex
+	ownAccountSkips = map[string]bool{}
+)
+
+//ownAccount reports whether client authenticates as account, for the apis that only serve the account the credentials belong to.
+//Subaccounts reached through a parent's credentials are skipped, and endpoint logs the first skip of each.
+func ownAccount(endpoint string, client *twilioClient, account string) bool {
+	if account == client.credentials.AccountSid() {
+		return true
+	}
+	ownAccountMu.Lock()
+	defer ownAccountMu.Unlock()
+	if key := endpoint + " " + account; !ownAccountSkips[key] {
+		ownAccountSkips[key] = true
+		log.Printf("%s: skipping subaccount %s, Twilio only serves it to its own credentials, list it under accounts to collect it", endpoint, account)
+	}
+	return false
+}
+
+//reportError logs err and sends it to ch as an invalid metric of desc, so the scrape shows the failure without dropping everything else
+func reportError(ch chan<- prometheus.Metric, desc *prometheus.Desc, err error) {
+	log.Println(err)
+	ch <- prometheus.NewInvalidMetric(desc, err)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//Workspaces is the outside object from Twilios TaskRouter Workspaces api
+type Workspaces struct {
+	Workspaces []WorkspaceRecords `json:"workspaces"`
+}
+
+//WorkspaceRecords are the inside objects from Twilios TaskRouter Workspaces api
+type WorkspaceRecords struct {
+	Sid          string `json:"sid"`
+	FriendlyName string `json:"friendly_name"`
+}
+
+//Workflows is the outside object from Twilios TaskRouter Workflows api
+type Workflows struct {
+	Workflows []WorkflowRecords `json:"workflows"`
+}
+
+//WorkflowRecords are the inside objects from Twilios TaskRouter Workflows api
+type WorkflowRecords struct {
+	Sid          string `json:"sid"`
+	FriendlyName string `json:"friendly_name"`
+}
+
+//TaskQueues is the outside object from Twilios TaskRouter TaskQueues api
+type TaskQueues struct {
+	TaskQueues []TaskQueueRecords `json:"task_queues"`
+}
+
+//TaskQueueRecords are the inside objects from Twilios TaskRouter TaskQueues api
+type TaskQueueRecords struct {
+	Sid          string `json:"sid"`
+	FriendlyName string `json:"friendly_name"`
+}
+
+//RealTimeStatistics is the object from Twilios TaskRouter RealTimeStatistics api of a workspace, workflow or task queue, each filling in the fields it has
+type RealTimeStatistics struct {
+	ActivityStatistics []struct {
+		FriendlyName string  `json:"friendly_name"`
+		Workers      float64 `json:"workers"`
+	} `json:"activity_statistics"`
+	LongestTaskWaitingAge float64            `json:"longest_task_waiting_age"`
+	TasksByStatus         map[string]float64 `json:"tasks_by_status"`
+	TotalAvailableWorkers float64            `json:"total_available_workers"`
+	TotalEligibleWorkers  float64            `json:"total_eligible_workers"`
+}
+
+//fetchWorkspaces returns every TaskRouter workspace of the account the credentials belong to
+func (t *twilioClient) fetchWorkspaces() ([]WorkspaceRecords, error) {
+	var workspaces []WorkspaceRecords
+	err := t.forEachPage(t.v1ListURL(t.taskRouterURL, "/Workspaces", nil), func(body []byte) error {
+		var bodyObject Workspaces
+		if err := json.Unmarshal(body, &bodyObject); err != nil {
+			return err
+		}
+		workspaces = append(workspaces, bodyObject.Workspaces...)
+		return nil
+	})
+	return workspaces, err
+}
+
+//fetchWorkflows returns every workflow of workspace
+func (t *twilioClient) fetchWorkflows(workspace string) ([]WorkflowRecords, error) {
+	var workflows []WorkflowRecords
+	err := t.forEachPage(t.v1ListURL(t.taskRouterURL, "/Workspaces/"+workspace+"/Workflows", nil), func(body []byte) error {
+		var bodyObject Workflows
+		if err := json.Unmarshal(body, &bodyObject); err != nil {
+			return err
+		}
+		workflows = append(workflows, bodyObject.Workflows...)
+		return nil
+	})
+	return workflows, err
+}
+
+//fetchTaskQueues returns every task queue of workspace
+func (t *twilioClient) fetchTaskQueues(workspace string) ([]TaskQueueRecords, error) {
+	var queues []TaskQueueRecords
+	err := t.forEachPage(t.v1ListURL(t.taskRouterURL, "/Workspaces/"+workspace+"/TaskQueues", nil), func(body []byte) error {
+		var bodyObject TaskQueues
+		if err := json.Unmarshal(body, &bodyObject); err != nil {
+			return err
+		}
+		queues = append(queues, bodyObject.TaskQueues...)
+		return nil
+	})
+	return queues, err
+}
+
+//fetchRealTimeStatistics returns the real-time statistics of the TaskRouter resource at path, such as /Workspaces/WS.../TaskQueues/WQ...
+func (t *twilioClient) fetchRealTimeStatistics(path string) (RealTimeStatistics, error) {
+	var stats RealTimeStatistics
+	err := t.getJSON(t.taskRouterURL+"/v1"+path+"/RealTimeStatistics", &stats)
+	return stats, err
+}
+
+//TaskRouterCollector exports the real-time statistics of every TaskRouter workspace along with its workflows and task queues.
+type TaskRouterCollector struct {
+	targets             []accountTarget
+	workers             *prometheus.Desc
+	tasks               *prometheus.Desc
+	longestWaiting      *prometheus.Desc
+	workflowTasks       *prometheus.Desc
+	workflowWaiting     *prometheus.Desc
+	queueWorkers        *prometheus.Desc
+	queueAvailable      *prometheus.Desc
+	queueEligible       *prometheus.Desc
+	queueTasks          *prometheus.Desc
+	queueLongestWaiting *prometheus.Desc
+}
+
+//newTaskRouterCollector creates a collector for the TaskRouter workspaces of targets
+func newTaskRouterCollector(targets []accountTarget) *TaskRouterCollector {
+	workspace := []string{"account_sid", "workspace_sid", "workspace"}
+	workflow := withLabels(workspace, "workflow_sid", "workflow")
+	queue := withLabels(workspace, "queue_sid", "queue")
+	return &TaskRouterCollector{
+		targets:             targets,
+		workers:             prometheus.NewDesc("twil_taskrouter_workers", "Workers of the workspace by activity", withLabels(workspace, "activity"), nil),
+		tasks:               prometheus.NewDesc("twil_taskrouter_tasks", "Tasks of the workspace by status", withLabels(workspace, "status"), nil),
+		longestWaiting:      prometheus.NewDesc("twil_taskrouter_longest_task_waiting_seconds", "Age of the longest waiting task of the workspace", workspace, nil),
+		workflowTasks:       prometheus.NewDesc("twil_taskrouter_workflow_tasks", "Tasks of the workflow by status", withLabels(workflow, "status"), nil),
+		workflowWaiting:     prometheus.NewDesc("twil_taskrouter_workflow_longest_task_waiting_seconds", "Age of the longest waiting task of the workflow", workflow, nil),
+		queueWorkers:        prometheus.NewDesc("twil_taskrouter_queue_workers", "Eligible workers of the task queue by activity", withLabels(queue, "activity"), nil),
+		queueAvailable:      prometheus.NewDesc("twil_taskrouter_queue_available_workers", "Workers of the task queue available to take a task", queue, nil),
+		queueEligible:       prometheus.NewDesc("twil_taskrouter_queue_eligible_workers", "Workers the task queue targets", queue, nil),
+		queueTasks:          prometheus.NewDesc("twil_taskrouter_queue_tasks", "Tasks of the task queue by status", withLabels(queue, "status"), nil),
+		queueLongestWaiting: prometheus.NewDesc("twil_taskrouter_queue_longest_task_waiting_seconds", "Age of the longest waiting task of the task queue", queue, nil),
+	}
+}
+
+//Describe initializes channels used to pull Metrics
+func (c *TaskRouterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.workers
+	ch <- c.tasks
+	ch <- c.longestWaiting
+	ch <- c.workflowTasks
+	ch <- c.workflowWaiting
+	ch <- c.queueWorkers
+	ch <- c.queueAvailable
+	ch <- c.queueEligible
+	ch <- c.queueTasks
+	ch <- c.queueLongestWaiting
+}
+
+//Collect gathers the metrics
+func (c *TaskRouterCollector) Collect(ch chan<- prometheus.Metric) {
+	eachAccount(ch, c.targets, "taskrouter", c.tasks, c.collectAccount)
+}
+
+//collectAccount emits the statistics of every workspace of account and records the health of the poll
+func (c *TaskRouterCollector) collectAccount(ch chan<- prometheus.Metric, client *twilioClient, account AccountRecords) {
+	if !ownAccount("taskrouter", client, account.Sid) {
+		return
+	}
+	start := time.Now()
+	workspaces, err := client.fetchWorkspaces()
+	if err != nil {
+		reportError(ch, c.tasks, fmt.Errorf("fetching workspaces of %s: %w", account.Sid, err))
+		recordPoll(account.Sid, "taskrouter", start, err)
+		return
+	}
+
+	var pollErr error
+	for _, workspace := range workspaces {
+		if err := c.collectWorkspace(ch, client, account.Sid, workspace); err != nil {
+			pollErr = err
+		}
+	}
+	recordPoll(account.Sid, "taskrouter", start, pollErr)
+}
+
+//collectWorkspace emits the statistics of workspace and its workflows and task queues, returning the last error it reported
+func (c *TaskRouterCollector) collectWorkspace(ch chan<- prometheus.Metric, client *twilioClient, account string, workspace WorkspaceRecords) error {
+	var lastErr error
+	report := func(desc *prometheus.Desc, err error) {
+		lastErr = err
+		reportError(ch, desc, err)
+	}
+	path := "/Workspaces/" + workspace.Sid
+	labels := []string{account, workspace.Sid, workspace.FriendlyName}
+
+	stats, err := client.fetchRealTimeStatistics(path)
+	if err != nil {
+		report(c.tasks, fmt.Errorf("fetching statistics of workspace %s of %s: %w", workspace.Sid, account, err))
+	} else {
+		for _, activity := range stats.ActivityStatistics {
+			ch <- prometheus.MustNewConstMetric(c.workers, prometheus.GaugeValue, activity.Workers, withLabels(labels, activity.FriendlyName)...)
+		}
+		for status, count := range stats.TasksByStatus {
+			ch <- prometheus.MustNewConstMetric(c.tasks, prometheus.GaugeValue, count, withLabels(labels, status)...)
+		}
+		ch <- prometheus.MustNewConstMetric(c.longestWaiting, prometheus.GaugeValue, stats.LongestTaskWaitingAge, labels...)
+	}
+
+	workflows, err := client.fetchWorkflows(workspace.Sid)
+	if err != nil {
+		report(c.workflowTasks, fmt.Errorf("fetching workflows of workspace %s of %s: %w", workspace.Sid, account, err))
+	}
+	for _, workflow := range workflows {
+		stats, err := client.fetchRealTimeStatistics(path + "/Workflows/" + workflow.Sid)
+		if err != nil {
+			report(c.workflowTasks, fmt.Errorf("fetching statistics of workflow %s of %s: %w", workflow.Sid, account, err))
+			continue
+		}
+		for status, count := range stats.TasksByStatus {
+			ch <- prometheus.MustNewConstMetric(c.workflowTasks, prometheus.GaugeValue, count, withLabels(labels, workflow.Sid, workflow.FriendlyName, status)...)
+		}
+		ch <- prometheus.MustNewConstMetric(c.workflowWaiting, prometheus.GaugeValue, stats.LongestTaskWaitingAge, withLabels(labels, workflow.Sid, workflow.FriendlyName)...)
+	}
+
+	queues, err := client.fetchTaskQueues(workspace.Sid)
+	if err != nil {
+		report(c.queueTasks, fmt.Errorf("fetching task queues of workspace %s of %s: %w", workspace.Sid, account, err))
+	}
+	for _, queue := range queues {
+		stats, err := client.fetchRealTimeStatistics(path + "/TaskQueues/" + queue.Sid)
+		if err != nil {
+			report(c.queueTasks, fmt.Errorf("fetching statistics of task queue %s of %s: %w", queue.Sid, account, err))
+			continue
+		}
+		for _, activity := range stats.ActivityStatistics {
+			ch <- prometheus.MustNewConstMetric(c.queueWorkers, prometheus.GaugeValue, activity.Workers, withLabels(labels, queue.Sid, queue.FriendlyName, activity.FriendlyName)...)
+		}
+		for status, count := range stats.TasksByStatus {
+			ch <- prometheus.MustNewConstMetric(c.queueTasks, prometheus.GaugeValue, count, withLabels(labels, queue.Sid, queue.FriendlyName, status)...)
+		}
+		ch <- prometheus.MustNewConstMetric(c.queueAvailable, prometheus.GaugeValue, stats.TotalAvailableWorkers, withLabels(labels, queue.Sid, queue.FriendlyName)...)
+		ch <- prometheus.MustNewConstMetric(c.queueEligible, prometheus.GaugeValue, stats.TotalEligibleWorkers, withLabels(labels, queue.Sid, queue.FriendlyName)...)
+		ch <- prometheus.MustNewConstMetric(c.queueLongestWaiting, prometheus.GaugeValue, stats.LongestTaskWaitingAge, withLabels(labels, queue.Sid, queue.FriendlyName)...)
+	}
+	return lastErr
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//twilioAPI is the default base URL that Twilio page URIs are relative to
+const twilioAPI = "https://api.twilio.com"
+
+//monitorAPI is the default base URL of Twilio's Monitor api
+const monitorAPI = "https://monitor.twilio.com"
+
+//taskRouterAPI is the default base URL of Twilio's TaskRouter api
+const taskRouterAPI = "https://taskrouter.twilio.com"
+
+//page is the paging envelope shared by Twilio's list resources, 2010-04-01 lists give a relative next_page_uri and the newer apis an absolute meta.next_page_url
+type page struct {
+	NextPageURI string `json:"next_page_uri"`
+	Meta        struct {
+		NextPageURL string `json:"next_page_url"`
+	} `json:"meta"`
+}
+
+//Accounts is the outside object from Twilios Accounts api
+type Accounts struct {
+	Accounts    []AccountRecords `json:"accounts"`
+	NextPageURI string           `json:"next_page_uri"`
+}
+
+//AccountRecords are the inside objects from Twilios Accounts api, one per account or subaccount
+type AccountRecords struct {
+	Sid             string `json:"sid"`
+	FriendlyName    string `json:"friendly_name"`
+	Status          string `json:"status"`
+	Type            string `json:"type"`
+	OwnerAccountSid string `json:"owner_account_sid"`
+}
+
+//twilioClient performs authenticated requests against the Twilio API, every one of them bound to ctx
+type twilioClient struct {
+	ctx           context.Context
+	client        http.Client
+	credentials   *credentialSource
+	baseURL       string
+	monitorURL    string
+	taskRouterURL string
+	pageSize      int
+	maxPages      int
+}
+
+//newTwilioClient returns a client authenticating with the latest credentials from credentials, using the endpoint, timeout, retry and paging settings of cfg
+func newTwilioClient(credentials *credentialSource, cfg *Config) *twilioClient {
+	return &twilioClient{
+		ctx: context.Background(),
+		client: http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: newRetryTransport(credentials.AccountSid(), cfg.Retry),
+		},
+		credentials:   credentials,
+		baseURL:       strings.TrimSuffix(cfg.APIURL, "/"),
+		monitorURL:    strings.TrimSuffix(cfg.MonitorURL, "/"),
+		taskRouterURL: strings.TrimSuffix(cfg.TaskRouterURL, "/"),
+		pageSize:      cfg.PageSize,
+		maxPages:      cfg.MaxPages,
+	}
+}
+
+//getBody performs an authenticated GET against reqURL and returns the response body, errors are *apiError
+func (t *twilioClient) getBody(reqURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(t.ctx, "GET", reqURL, nil)
+	if err != nil {
+		return nil, newAPIError(reasonNetwork, err)
+	}
+
+	credentials, err := t.credentials.Credentials()
+	if err != nil {
+		log.Println(err)
+	}
+	req.SetBasicAuth(credentials.BasicAuth())
+	req.Header.Add("User-Agent", "twil")
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return nil, newAPIError(reasonNetwork, err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, newAPIError(reasonNetwork, err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, statusError("GET", reqURL, res.StatusCode, res.Status, body)
+	}
+	return body, nil
+}
+
+//getJSON performs an authenticated GET against reqURL and decodes the response into v
+func (t *twilioClient) getJSON(reqURL string, v interface{}) error {
+	body, err := t.getBody(reqURL)
+	if err != nil {
+		return err
+	}
+	return decodeError(reqURL, json.Unmarshal(body, v))
+}
+
+//errStopPaging is returned by a forEachPage callback that has read all the pages it needs
+var errStopPaging = errors.New("stop paging")
+
+//errPageLimit is returned by forEachPage when it stopped at maxPages with pages left to read
+var errPageLimit = errors.New("stopped at max_pages with pages left, raise max_pages or set it to 0 for no limit")
+
+//forEachPage hands every page of a Twilio list resource to fn, following the next page until there is none or fn returns errStopPaging.
+//fn only decodes the page, so its other errors are reported as decode failures. Reaching maxPages with pages left fails with errPageLimit, after fn has seen the pages read.
+func (t *twilioClient) forEachPage(reqURL string, fn func(body []byte) error) error {
+	for n := 0; reqURL != ""; n++ {
+		if t.maxPages > 0 && n >= t.maxPages {
+			return newAPIError(reasonPageLimit, fmt.Errorf("%s: %w", reqURL, errPageLimit))
+		}
+
+		body, err := t.getBody(reqURL)
+		if err != nil {
+			return err
+		}
+		if err := fn(body); err == errStopPaging {
+			return nil
+		} else if err != nil {
+			return decodeError(reqURL, err)
+		}
+
+		var p page
+		if err := json.Unmarshal(body, &p); err != nil {
+			return decodeError(reqURL, err)
+		}
+		switch {
+		case p.NextPageURI != "":
+			reqURL = t.baseURL + p.NextPageURI
+		case p.Meta.NextPageURL != "":
+			next, err := samePlace(reqURL, p.Meta.NextPageURL)
+			if err != nil {
+				return decodeError(reqURL, err)
+			}
+			reqURL = next
+		default:
+			reqURL = ""
+		}
+	}
+	return nil
+}
+
+//samePlace points the absolute next URL at the scheme and host of current, so a configured base URL keeps being used past the first page
+func samePlace(current string, next string) (string, error) {
+	cur, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(next)
+	if err != nil {
+		return "", err
+	}
+	u.Scheme, u.Host = cur.Scheme, cur.Host
+	return u.String(), nil
+}
+
+//listURL builds the URL of a list resource under /2010-04-01 with the configured page size
+func (t *twilioClient) listURL(path string, query url.Values) string {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("PageSize", strconv.Itoa(t.pageSize))
+	return t.baseURL + "/2010-04-01" + path + "?" + query.Encode()
+}
+
+//v1ListURL builds the URL of a list resource of a v1 api such as Monitor or TaskRouter, base is its base URL, with the configured page size
+func (t *twilioClient) v1ListURL(base string, path string, query url.Values) string {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("PageSize", strconv.Itoa(t.pageSize))
+	return base + "/v1" + path + "?" + query.Encode()
+}
+
+//fetchAccount looks up a single account by sid
+func (t *twilioClient) fetchAccount(sid string) (AccountRecords, error) {
+	var account AccountRecords
+	err := t.getJSON(t.baseURL+"/2010-04-01/Accounts/"+sid+".json", &account)
+	return account, err
+}
+
+//listAccounts returns every active account visible to the credentials whose sid or friendly name matches filter, a nil filter matches everything
+func (t *twilioClient) listAccounts(filter *regexp.Regexp) ([]AccountRecords, error) {
+	var accounts []AccountRecords
+	err := t.forEachPage(t.listURL("/Accounts.json", url.Values{"Status": {"active"}}), func(body []byte) error {
+		var bodyObject Accounts
+		if err := json.Unmarshal(body, &bodyObject); err != nil {
+			return err
+		}
+		for _, account := range bodyObject.Accounts {
+			if account.Status != "active" {
+				continue
+			}
+			if filter != nil && !filter.MatchString(account.Sid) && !filter.MatchString(account.FriendlyName) {
+				continue
+			}
+			accounts = append(accounts, account)
+		}
+		return nil
+	})
+	return accounts, err
+}
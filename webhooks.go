@@ -0,0 +1,226 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//Reasons a status callback is rejected, the values of the reason label of twil_webhook_rejected_total
+const (
+	rejectMethod    = "method"
+	rejectForm      = "form"
+	rejectBody      = "body"
+	rejectAccount   = "account"
+	rejectSignature = "signature"
+)
+
+//webhookBodyLimit bounds the size of a status callback body
+const webhookBodyLimit = 64 << 10
+
+//callbackMemory is how long the statuses seen for a message or call are remembered, to measure latency and ignore retried callbacks
+const callbackMemory = time.Hour
+
+//Status callback metrics, registered once so they survive configuration reloads
+var (
+	webhookMessagesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "twil_webhook_messages_total",
+		Help: "Message status callbacks received, by status and error code",
+	}, []string{"account_sid", "status", "error_code"})
+	webhookCallsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "twil_webhook_calls_total",
+		Help: "Call status callbacks received, by status and direction",
+	}, []string{"account_sid", "status", "direction"})
+	webhookLatencyHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "twil_webhook_message_latency_seconds",
+		Help:    "Time between the status callbacks of a message, by stage: queued_to_sent, sent_to_delivered or queued_to_delivered",
+		Buckets: prometheus.ExponentialBuckets(0.25, 2, 12),
+	}, []string{"account_sid", "stage"})
+	webhookRejectedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "twil_webhook_rejected_total",
+		Help: "Status callbacks and Event Streams deliveries rejected by reason: method, form, body, account or signature",
+	}, []string{"reason"})
+)
+
+//webhookCollectors are the status callback metrics to register
+var webhookCollectors = []prometheus.Collector{webhookMessagesCounter, webhookCallsCounter, webhookLatencyHistogram, webhookRejectedCounter}
+
+//callbackTracker remembers when each status of a message or call was first reported
+type callbackTracker struct {
+	mu        sync.Mutex
+	seen      map[string]map[string]time.Time
+	lastSweep time.Time
+}
+
+//newCallbackTracker creates an empty tracker
+func newCallbackTracker() *callbackTracker {
+	return &callbackTracker{seen: map[string]map[string]time.Time{}, lastSweep: time.Now()}
+}
+
+//record notes that sid reached status at now, returning the times of its earlier statuses and whether status is new for sid
+func (t *callbackTracker) record(sid string, status string, now time.Time) (map[string]time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.Sub(t.lastSweep) > time.Minute {
+		for s, statuses := range t.seen {
+			if now.Sub(statuses[""]) > callbackMemory {
+				delete(t.seen, s)
+			}
+		}
+		t.lastSweep = now
+	}
+
+	statuses, ok := t.seen[sid]
+	if !ok {
+		//the empty status holds when sid was first seen
+		statuses = map[string]time.Time{"": now}
+		t.seen[sid] = statuses
+	}
+	if _, dup := statuses[status]; dup {
+		return nil, false
+	}
+	earlier := make(map[string]time.Time, len(statuses))
+	for s, at := range statuses {
+		earlier[s] = at
+	}
+	statuses[status] = now
+	return earlier, true
+}
+
+//webhookHandler serves Twilio status callbacks of kind messages or calls, checking the X-Twilio-Signature of each against the Auth Token of its account.
+//Only accounts configured with an Auth Token can be checked, callbacks for any other account are rejected.
+func webhookHandler(running *reloadableCollector, kind string) http.Handler {
+	tracker := newCallbackTracker()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := running.config()
+		if !cfg.Webhooks.Enabled {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			webhookRejectedCounter.WithLabelValues(rejectMethod).Inc()
+			http.Error(w, "status callbacks must be POSTed", http.StatusMethodNotAllowed)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, webhookBodyLimit)
+		if err := r.ParseForm(); err != nil {
+			webhookRejectedCounter.WithLabelValues(rejectForm).Inc()
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		sid := r.PostForm.Get("AccountSid")
+		token, ok := authToken(running.credentials(), sid)
+		if !ok {
+			webhookRejectedCounter.WithLabelValues(rejectAccount).Inc()
+			log.Printf("rejected %s status callback for account %q without a configured Auth Token", kind, sid)
+			http.Error(w, "unknown account", http.StatusForbidden)
+			return
+		}
+		if !validSignature(token, callbackURL(cfg, r), r.PostForm, r.Header.Get("X-Twilio-Signature")) {
+			webhookRejectedCounter.WithLabelValues(rejectSignature).Inc()
+			log.Printf("rejected %s status callback for account %s with an invalid signature", kind, sid)
+			http.Error(w, "invalid signature", http.StatusForbidden)
+			return
+		}
+
+		switch kind {
+		case "messages":
+			recordMessageCallback(tracker, sid, r.PostForm, time.Now())
+		case "calls":
+			recordCallCallback(tracker, sid, r.PostForm, time.Now())
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+//recordMessageCallback counts a message status callback once per status and observes the time since the message's earlier statuses
+func recordMessageCallback(tracker *callbackTracker, account string, form url.Values, now time.Time) {
+	status := form.Get("MessageStatus")
+	earlier, fresh := tracker.record(form.Get("MessageSid"), status, now)
+	if !fresh {
+		return
+	}
+	webhookMessagesCounter.WithLabelValues(account, status, form.Get("ErrorCode")).Inc()
+
+	observe := func(from string, stage string) {
+		if at, ok := earlier[from]; ok {
+			webhookLatencyHistogram.WithLabelValues(account, stage).Observe(now.Sub(at).Seconds())
+		}
+	}
+	switch status {
+	case "sent":
+		observe("queued", "queued_to_sent")
+	case "delivered":
+		observe("sent", "sent_to_delivered")
+		observe("queued", "queued_to_delivered")
+	}
+}
+
+//recordCallCallback counts a call status callback once per status
+func recordCallCallback(tracker *callbackTracker, account string, form url.Values, now time.Time) {
+	status := form.Get("CallStatus")
+	if _, fresh := tracker.record(form.Get("CallSid"), status, now); fresh {
+		webhookCallsCounter.WithLabelValues(account, status, form.Get("Direction")).Inc()
+	}
+}
+
+//authToken returns the current Auth Token of the configured account sid from the credential sources of the running collectors
+func authToken(sources map[string]*credentialSource, sid string) (string, bool) {
+	source, ok := sources[sid]
+	if !ok {
+		return "", false
+	}
+	credentials, err := source.Credentials()
+	if err != nil {
+		log.Println(err)
+	}
+	return credentials.AuthToken, credentials.AuthToken != ""
+}
+
+//callbackURL returns the URL Twilio requested, under webhooks.public_url when it is set and otherwise as seen by twil, honoring X-Forwarded-Proto
+func callbackURL(cfg *Config, r *http.Request) string {
+	if cfg.Webhooks.PublicURL != "" {
+		return strings.TrimSuffix(cfg.Webhooks.PublicURL, "/") + r.URL.RequestURI()
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+//validSignature checks signature is Twilio's signature of the callback to callbackURL with the POST parameters form, signed with token.
+//Twilio signs the URL followed by every parameter name and value sorted by name, with HMAC-SHA1.
+func validSignature(token string, callbackURL string, form url.Values, signature string) bool {
+	keys := make([]string, 0, len(form))
+	for k := range form {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	mac := hmac.New(sha1.New, []byte(token))
+	mac.Write([]byte(callbackURL))
+	for _, k := range keys {
+		values := append([]string(nil), form[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			mac.Write([]byte(k + v))
+		}
+	}
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}